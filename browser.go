@@ -0,0 +1,272 @@
+package pinboard
+
+import (
+	"encoding/json"
+	"io"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// FolderMode is how the folders a browser bookmark is in become tags.
+type FolderMode int
+
+const (
+	// FolderEach makes each folder in the path a tag.
+	FolderEach FolderMode = iota
+
+	// FolderPath makes the whole path a single tag, with folders
+	// joined by the separator.
+	FolderPath
+
+	// FolderLast makes the innermost folder a tag.
+	FolderLast
+
+	// FolderNone ignores folders.
+	FolderNone
+)
+
+// FolderOptions represents the optional arguments for turning
+// folders into tags. Spaces and commas in folder names, which
+// Pinboard tags can't contain, are replaced by dashes.
+type FolderOptions struct {
+	// Defaults to FolderEach.
+	Mode FolderMode
+
+	// Separator between folders with FolderPath. Defaults to "/".
+	Separator string
+
+	// Keep the browser's top level folders, such as the bookmarks
+	// bar or menu, in the path.
+	KeepRoots bool
+}
+
+// tags returns the tags for a bookmark in the given folders,
+// outermost first.
+func (o *FolderOptions) tags(path []string) []string {
+	if o == nil {
+		o = &FolderOptions{}
+	}
+
+	var names []string
+	for _, f := range path {
+		if f = tagName(f); f != "" {
+			names = append(names, f)
+		}
+	}
+
+	if len(names) == 0 {
+		return nil
+	}
+
+	switch o.Mode {
+	case FolderPath:
+		return []string{strings.Join(names, firstNonEmpty(o.Separator, "/"))}
+	case FolderLast:
+		return names[len(names)-1:]
+	case FolderNone:
+		return nil
+	}
+
+	return names
+}
+
+// browserScheme reports whether a bookmark's URL can be saved in
+// Pinboard, leaving out bookmarklets and browser internal pages.
+func browserScheme(rawurl string) bool {
+	u, err := url.Parse(rawurl)
+	if err != nil {
+		return false
+	}
+
+	switch strings.ToLower(u.Scheme) {
+	case "http", "https", "ftp":
+		return true
+	}
+
+	return false
+}
+
+// chromeEpoch is the number of seconds from 1601-01-01, when Chrome
+// timestamps start, to the Unix epoch.
+const chromeEpoch = 11644473600
+
+// chromeTime converts a Chrome timestamp, microseconds since
+// 1601-01-01 UTC, to a time. An empty or zero timestamp gives the zero
+// time.
+func chromeTime(s string) time.Time {
+	us, err := strconv.ParseInt(s, 10, 64)
+	if err != nil || us <= 0 {
+		return time.Time{}
+	}
+
+	return time.Unix(us/1e6-chromeEpoch, us%1e6*1e3).UTC()
+}
+
+// microTime converts microseconds since the Unix epoch to a time.
+func microTime(us int64) time.Time {
+	if us <= 0 {
+		return time.Time{}
+	}
+
+	return time.Unix(us/1e6, us%1e6*1e3).UTC()
+}
+
+type chromeNode struct {
+	Type      string       `json:"type"`
+	Name      string       `json:"name"`
+	URL       string       `json:"url"`
+	DateAdded string       `json:"date_added"`
+	Children  []chromeNode `json:"children"`
+}
+
+// ParseChrome reads the Bookmarks file of Chrome, Chromium and the
+// browsers based on them, found in the browser's profile directory.
+// Only http, https and ftp bookmarks are returned.
+func ParseChrome(r io.Reader, opt *FolderOptions) ([]*PostsAddOptions, error) {
+	var file struct {
+		Roots struct {
+			BookmarkBar chromeNode `json:"bookmark_bar"`
+			Other       chromeNode `json:"other"`
+			Synced      chromeNode `json:"synced"`
+		} `json:"roots"`
+	}
+
+	err := json.NewDecoder(r).Decode(&file)
+	if err != nil {
+		return nil, err
+	}
+
+	var items []*PostsAddOptions
+	var walk func(n chromeNode, path []string)
+	walk = func(n chromeNode, path []string) {
+		switch n.Type {
+		case "folder":
+			for _, c := range n.Children {
+				walk(c, append(path[:len(path):len(path)], n.Name))
+			}
+		case "url":
+			if !browserScheme(n.URL) {
+				return
+			}
+			items = append(items, &PostsAddOptions{
+				URL:         n.URL,
+				Description: firstNonEmpty(strings.TrimSpace(n.Name), n.URL),
+				Tags:        opt.tags(path),
+				Dt:          chromeTime(n.DateAdded),
+			})
+		}
+	}
+
+	// The roots are the bookmarks bar, other bookmarks and mobile
+	// bookmarks, in the order the browser shows them.
+	for _, root := range []chromeNode{file.Roots.BookmarkBar, file.Roots.Other, file.Roots.Synced} {
+		if opt != nil && opt.KeepRoots {
+			walk(root, nil)
+			continue
+		}
+
+		for _, c := range root.Children {
+			walk(c, nil)
+		}
+	}
+
+	return items, nil
+}
+
+type firefoxNode struct {
+	GUID      string        `json:"guid"`
+	Title     string        `json:"title"`
+	Type      string        `json:"type"`
+	Root      string        `json:"root"`
+	URI       string        `json:"uri"`
+	DateAdded int64         `json:"dateAdded"`
+	Tags      string        `json:"tags"`
+	Annos     []firefoxAnno `json:"annos"`
+	Children  []firefoxNode `json:"children"`
+}
+
+type firefoxAnno struct {
+	Name  string      `json:"name"`
+	Value interface{} `json:"value"`
+}
+
+// ParseFirefox reads a Firefox bookmarks backup, the
+// bookmarks-<date>.json files written by "Backup…" in the Library
+// window. The bookmark's Firefox tags are kept along with those made
+// from its folders, and its description, if any, becomes Extended.
+// Only http, https and ftp bookmarks are returned.
+func ParseFirefox(r io.Reader, opt *FolderOptions) ([]*PostsAddOptions, error) {
+	var root firefoxNode
+	err := json.NewDecoder(r).Decode(&root)
+	if err != nil {
+		return nil, err
+	}
+
+	var items []*PostsAddOptions
+	var walk func(n firefoxNode, path []string)
+	walk = func(n firefoxNode, path []string) {
+		switch n.Type {
+		case "text/x-moz-place-container":
+			// Old backups keep tags as folders of their own.
+			if n.Root == "tagsFolder" || n.GUID == "tags________" {
+				return
+			}
+			for _, c := range n.Children {
+				walk(c, append(path[:len(path):len(path)], n.Title))
+			}
+		case "text/x-moz-place":
+			if !browserScheme(n.URI) {
+				return
+			}
+
+			item := &PostsAddOptions{
+				URL:         n.URI,
+				Description: firstNonEmpty(strings.TrimSpace(n.Title), n.URI),
+				Tags:        opt.tags(path),
+				Dt:          microTime(n.DateAdded),
+			}
+
+			var tags []string
+			for _, t := range strings.Split(n.Tags, ",") {
+				if t = tagName(t); t != "" {
+					tags = append(tags, t)
+				}
+			}
+			item.Tags = unionTags(item.Tags, tags)
+
+			for _, a := range n.Annos {
+				if s, ok := a.Value.(string); ok && a.Name == "bookmarkProperties/description" {
+					item.Extended = []byte(s)
+				}
+			}
+
+			items = append(items, item)
+		}
+	}
+
+	// The top level folders are the menu, toolbar, other
+	// bookmarks and mobile bookmarks.
+	for _, top := range root.Children {
+		if opt != nil && opt.KeepRoots {
+			walk(top, nil)
+			continue
+		}
+
+		if top.Type != "text/x-moz-place-container" {
+			walk(top, nil)
+			continue
+		}
+
+		if top.Root == "tagsFolder" || top.GUID == "tags________" {
+			continue
+		}
+
+		for _, c := range top.Children {
+			walk(c, nil)
+		}
+	}
+
+	return items, nil
+}
@@ -0,0 +1,160 @@
+package pinboard
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+const testChromeBookmarks = `{
+   "checksum": "0123456789abcdef",
+   "roots": {
+      "bookmark_bar": {
+         "children": [ {
+            "date_added": "12936570482000000",
+            "id": "2",
+            "name": "The Go Programming Language",
+            "type": "url",
+            "url": "https://golang.org/"
+         }, {
+            "children": [ {
+               "date_added": "13245678901234567",
+               "id": "4",
+               "name": "",
+               "type": "url",
+               "url": "https://example.com/"
+            }, {
+               "date_added": "13245678901234567",
+               "id": "5",
+               "name": "Bookmarklet",
+               "type": "url",
+               "url": "javascript:alert(1)"
+            } ],
+            "date_added": "13245678900000000",
+            "id": "3",
+            "name": "Dev Tools, Misc",
+            "type": "folder"
+         } ],
+         "date_added": "13245678900000000",
+         "id": "1",
+         "name": "Bookmarks bar",
+         "type": "folder"
+      },
+      "other": {
+         "children": [ ],
+         "id": "6",
+         "name": "Other bookmarks",
+         "type": "folder"
+      },
+      "synced": {
+         "children": [ ],
+         "id": "7",
+         "name": "Mobile bookmarks",
+         "type": "folder"
+      }
+   },
+   "version": 1
+}`
+
+const testFirefoxBackup = `{
+  "guid": "root________", "title": "", "type": "text/x-moz-place-container", "root": "placesRoot",
+  "children": [
+    {"guid": "menu________", "title": "menu", "type": "text/x-moz-place-container", "root": "bookmarksMenuFolder",
+     "children": [
+       {"guid": "a", "title": "Reading", "type": "text/x-moz-place-container",
+        "children": [
+          {"guid": "b", "title": "Sub Folder", "type": "text/x-moz-place-container",
+           "children": [
+             {"guid": "c", "title": "Go", "type": "text/x-moz-place", "uri": "https://golang.org/",
+              "dateAdded": 1292096882000000, "tags": "lang,go",
+              "annos": [{"name": "bookmarkProperties/description", "value": "Go site"}]}
+           ]}
+        ]},
+       {"guid": "d", "type": "text/x-moz-place-separator"},
+       {"guid": "e", "title": "Most Visited", "type": "text/x-moz-place", "uri": "place:sort=8&maxResults=10"}
+     ]},
+    {"guid": "tags________", "title": "tags", "type": "text/x-moz-place-container", "root": "tagsFolder",
+     "children": [{"guid": "f", "title": "go", "type": "text/x-moz-place-container",
+       "children": [{"guid": "g", "type": "text/x-moz-place", "uri": "https://golang.org/"}]}]},
+    {"guid": "unfiled_____", "title": "unfiled", "type": "text/x-moz-place-container", "root": "unfiledBookmarksFolder",
+     "children": [{"guid": "h", "title": "Example", "type": "text/x-moz-place", "uri": "https://example.com/", "dateAdded": 1}]}
+  ]
+}`
+
+func TestChromeTime(t *testing.T) {
+	// 2010-12-11T19:48:02Z in Chrome's epoch.
+	got := chromeTime("12936570482000000")
+	if !got.Equal(time.Date(2010, 12, 11, 19, 48, 2, 0, time.UTC)) {
+		t.Errorf("error: got %v, expected 2010-12-11T19:48:02Z", got)
+	}
+
+	got = chromeTime("13245678901234567")
+	if got.Nanosecond() != 234567000 {
+		t.Errorf("error: got %v nanoseconds, expected 234567000", got.Nanosecond())
+	}
+
+	if !chromeTime("").IsZero() || !chromeTime("0").IsZero() {
+		t.Error("error: expected zero time for missing timestamp")
+	}
+}
+
+func TestParseChrome(t *testing.T) {
+	items, err := ParseChrome(strings.NewReader(testChromeBookmarks), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(items) != 2 {
+		t.Fatalf("error: got %v items, expected 2", len(items))
+	}
+
+	if items[0].Description != "The Go Programming Language" || len(items[0].Tags) != 0 || items[0].Dt.Year() != 2010 {
+		t.Errorf("error: got %+v", items[0])
+	}
+
+	if items[1].Description != "https://example.com/" || len(items[1].Tags) != 1 || items[1].Tags[0] != "Dev-Tools-Misc" {
+		t.Errorf("error: got %+v", items[1])
+	}
+
+	items, err = ParseChrome(strings.NewReader(testChromeBookmarks), &FolderOptions{Mode: FolderPath, Separator: ":", KeepRoots: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if items[1].Tags[0] != "Bookmarks-bar:Dev-Tools-Misc" {
+		t.Errorf("error: got tags %v", items[1].Tags)
+	}
+}
+
+func TestParseFirefox(t *testing.T) {
+	items, err := ParseFirefox(strings.NewReader(testFirefoxBackup), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(items) != 2 {
+		t.Fatalf("error: got %v items, expected 2", len(items))
+	}
+
+	g := items[0]
+	if g.URL != "https://golang.org/" || string(g.Extended) != "Go site" {
+		t.Errorf("error: got %+v", g)
+	}
+
+	if strings.Join(g.Tags, " ") != "Reading Sub-Folder lang go" {
+		t.Errorf("error: got tags %v", g.Tags)
+	}
+
+	if !g.Dt.Equal(time.Date(2010, 12, 11, 19, 48, 2, 0, time.UTC)) {
+		t.Errorf("error: got %v", g.Dt)
+	}
+
+	items, err = ParseFirefox(strings.NewReader(testFirefoxBackup), &FolderOptions{Mode: FolderLast})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if strings.Join(items[0].Tags, " ") != "Sub-Folder lang go" || len(items[1].Tags) != 0 {
+		t.Errorf("error: got tags %v and %v", items[0].Tags, items[1].Tags)
+	}
+}
@@ -0,0 +1,264 @@
+package pinboard
+
+import (
+	"bytes"
+	"fmt"
+	"net/url"
+	"sort"
+	"strings"
+)
+
+// Canonicalizer rewrites URLs into a canonical form so that the same
+// page saved under slightly different URLs can be recognised.
+type Canonicalizer struct {
+	// Use https for http URLs.
+	ForceHTTPS bool
+
+	// Remove a leading "www." from the host.
+	StripWWW bool
+
+	// Remove a trailing slash from the path.
+	StripTrailingSlash bool
+
+	// Remove the #fragment.
+	StripFragment bool
+
+	// Sort the remaining query parameters.
+	SortQuery bool
+
+	// Query parameters to remove. A name ending in * matches any
+	// parameter with that prefix, as in "utm_*".
+	StripParams []string
+}
+
+// DefaultCanonicalizer applies every rule and removes common tracking
+// parameters.
+var DefaultCanonicalizer = &Canonicalizer{
+	ForceHTTPS:         true,
+	StripWWW:           true,
+	StripTrailingSlash: true,
+	StripFragment:      true,
+	SortQuery:          true,
+	StripParams: []string{
+		"utm_*",
+		"fbclid",
+		"gclid",
+		"dclid",
+		"msclkid",
+		"mc_cid",
+		"mc_eid",
+		"igshid",
+		"ref_src",
+	},
+}
+
+// Canonicalize returns the canonical form of rawurl. The scheme and
+// host are always lower cased and default ports removed.
+func (c *Canonicalizer) Canonicalize(rawurl string) (string, error) {
+	u, err := url.Parse(rawurl)
+	if err != nil {
+		return "", err
+	}
+
+	if !u.IsAbs() {
+		return "", fmt.Errorf("error: %s is not an absolute url", rawurl)
+	}
+
+	return c.canonical(u), nil
+}
+
+// canonical returns the canonical form of u without modifying it.
+func (c *Canonicalizer) canonical(u *url.URL) string {
+	cu := *u
+	cu.Scheme = strings.ToLower(cu.Scheme)
+	cu.Host = strings.ToLower(cu.Host)
+
+	if c.ForceHTTPS && cu.Scheme == "http" {
+		cu.Scheme = "https"
+	}
+
+	host, port := cu.Hostname(), cu.Port()
+	if (port == "80" && cu.Scheme == "http") || (port == "443" && cu.Scheme == "https") {
+		port = ""
+	}
+
+	if c.StripWWW {
+		host = strings.TrimPrefix(host, "www.")
+	}
+
+	cu.Host = host
+	if port != "" {
+		cu.Host = host + ":" + port
+	}
+
+	if c.StripTrailingSlash {
+		cu.Path = strings.TrimRight(cu.Path, "/")
+		cu.RawPath = strings.TrimRight(cu.RawPath, "/")
+	}
+
+	if c.StripFragment {
+		cu.Fragment = ""
+		cu.RawFragment = ""
+	}
+
+	var params []string
+	for _, param := range strings.Split(cu.RawQuery, "&") {
+		if param == "" {
+			continue
+		}
+
+		name := param
+		if i := strings.IndexByte(param, '='); i >= 0 {
+			name = param[:i]
+		}
+		if n, err := url.QueryUnescape(name); err == nil {
+			name = n
+		}
+
+		if !c.strip(name) {
+			params = append(params, param)
+		}
+	}
+
+	if c.SortQuery {
+		sort.Strings(params)
+	}
+	cu.RawQuery = strings.Join(params, "&")
+	cu.ForceQuery = false
+
+	return cu.String()
+}
+
+// strip reports whether the query parameter name should be removed.
+func (c *Canonicalizer) strip(name string) bool {
+	name = strings.ToLower(name)
+	for _, p := range c.StripParams {
+		p = strings.ToLower(p)
+		if strings.HasSuffix(p, "*") {
+			if strings.HasPrefix(name, strings.TrimSuffix(p, "*")) {
+				return true
+			}
+		} else if name == p {
+			return true
+		}
+	}
+
+	return false
+}
+
+// FindDuplicates groups posts whose URLs have the same canonical
+// form. Only groups with more than one post are returned, each sorted
+// oldest first. If c is nil DefaultCanonicalizer is used.
+func FindDuplicates(posts []*Post, c *Canonicalizer) [][]*Post {
+	if c == nil {
+		c = DefaultCanonicalizer
+	}
+
+	var keys []string
+	groups := make(map[string][]*Post)
+	for _, p := range posts {
+		key := c.canonical(p.Href)
+		if _, ok := groups[key]; !ok {
+			keys = append(keys, key)
+		}
+		groups[key] = append(groups[key], p)
+	}
+
+	var dups [][]*Post
+	for _, key := range keys {
+		group := groups[key]
+		if len(group) < 2 {
+			continue
+		}
+
+		sort.SliceStable(group, func(i, j int) bool {
+			return group[i].Time.Before(group[j].Time)
+		})
+		dups = append(dups, group)
+	}
+
+	return dups
+}
+
+// MergeDuplicates combines a group of duplicate posts into the oldest
+// one. It keeps the oldest post's URL, time and privacy flags, unions
+// the tags and concatenates the Extended text of every post. It
+// returns the options to write the merged post along with the URLs of
+// the other posts, which should be deleted.
+func MergeDuplicates(group []*Post) (*PostsAddOptions, []string) {
+	if len(group) == 0 {
+		return nil, nil
+	}
+
+	oldest := group[0]
+	for _, p := range group[1:] {
+		if p.Time.Before(oldest.Time) {
+			oldest = p
+		}
+	}
+
+	merged := oldest.addOptions()
+
+	// The first title found, for an untitled oldest post.
+	title := oldest.Description
+
+	var extended [][]byte
+	var extras []string
+	for _, p := range group {
+		if title == "" {
+			title = p.Description
+		}
+
+		merged.Tags = unionTags(merged.Tags, p.Tags)
+
+		text := bytes.TrimSpace(p.Extended)
+		seen := false
+		for _, e := range extended {
+			if bytes.Equal(e, text) {
+				seen = true
+			}
+		}
+		if len(text) > 0 && !seen {
+			extended = append(extended, text)
+		}
+
+		if p != oldest {
+			extras = append(extras, p.Href.String())
+		}
+	}
+
+	merged.Extended = bytes.Join(extended, []byte("\n\n"))
+	if title != "" {
+		merged.Description = title
+	}
+
+	return merged, extras
+}
+
+// Merge merges a group of duplicate posts with MergeDuplicates, saves
+// the merged post with PostsAdd and removes the others with
+// PostsDelete, waiting RateLimit between calls.
+func Merge(group []*Post) error {
+	merged, extras := MergeDuplicates(group)
+	if merged == nil {
+		return nil
+	}
+
+	pc := &pacer{interval: RateLimit}
+
+	pc.wait()
+	err := PostsAdd(merged)
+	if err != nil {
+		return err
+	}
+
+	for _, u := range extras {
+		pc.wait()
+		err := PostsDelete(u)
+		if err != nil {
+			return fmt.Errorf("error: %s: %s", u, err)
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,97 @@
+package pinboard
+
+import (
+	"testing"
+	"time"
+)
+
+func TestCanonicalize(t *testing.T) {
+	tests := []struct {
+		url      string
+		expected string
+	}{
+		{"http://www.Example.com/article/", "https://example.com/article"},
+		{"https://example.com:443/article?utm_source=x&b=2&a=1&fbclid=abc", "https://example.com/article?a=1&b=2"},
+		{"https://example.com/article#comments", "https://example.com/article"},
+		{"https://example.com/?utm_medium=email", "https://example.com"},
+	}
+
+	for _, test := range tests {
+		got, err := DefaultCanonicalizer.Canonicalize(test.url)
+		if err != nil {
+			t.Error(err)
+		}
+
+		if got != test.expected {
+			t.Errorf("error: got %v, expected %v", got, test.expected)
+		}
+	}
+
+	c := &Canonicalizer{StripParams: []string{"ref"}}
+	got, _ := c.Canonicalize("http://www.example.com/a/?z=1&ref=x&a=2")
+	expected := "http://www.example.com/a/?z=1&a=2"
+	if got != expected {
+		t.Errorf("error: got %v, expected %v", got, expected)
+	}
+
+	_, err := c.Canonicalize("/relative")
+	if err == nil {
+		t.Error("error: expected relative url error")
+	}
+}
+
+func TestFindAndMergeDuplicates(t *testing.T) {
+	first := testPost("http://www.example.com/article/", "Article", "news")
+	first.Extended = []byte("First notes.")
+
+	second := testPost("https://example.com/article?utm_source=twitter", "", "reading")
+	second.Time = first.Time.Add(time.Hour)
+	second.Extended = []byte("Second notes.")
+
+	third := testPost("https://example.com/article", "Article (again)", "news")
+	third.Time = first.Time.Add(2 * time.Hour)
+
+	other := testPost("https://example.com/other", "Other")
+
+	dups := FindDuplicates([]*Post{third, other, second, first}, nil)
+	if len(dups) != 1 || len(dups[0]) != 3 {
+		t.Fatalf("error: expected 1 group of 3 duplicates, got %v", dups)
+	}
+
+	if dups[0][0] != first {
+		t.Error("error: expected group to be sorted oldest first")
+	}
+
+	merged, extras := MergeDuplicates(dups[0])
+	if merged.URL != "http://www.example.com/article/" || !merged.Dt.Equal(first.Time) {
+		t.Errorf("error: expected oldest post to be kept, got %v", merged)
+	}
+
+	if len(merged.Tags) != 2 {
+		t.Errorf("error: got tags %v, expected [news reading]", merged.Tags)
+	}
+
+	if string(merged.Extended) != "First notes.\n\nSecond notes." {
+		t.Errorf("error: got extended %q", merged.Extended)
+	}
+
+	if len(extras) != 2 {
+		t.Errorf("error: got %v, expected 2 extras to delete", len(extras))
+	}
+}
+
+func TestMergeDuplicatesUntitled(t *testing.T) {
+	untitled := testPost("https://example.com/article", "")
+	titled := testPost("https://example.com/article/", "Article")
+	titled.Time = untitled.Time.Add(time.Hour)
+
+	merged, _ := MergeDuplicates([]*Post{untitled, titled})
+	if merged.URL != "https://example.com/article" || merged.Description != "Article" {
+		t.Errorf("error: got %q titled %q, expected the oldest url with the first title", merged.URL, merged.Description)
+	}
+
+	merged, _ = MergeDuplicates([]*Post{untitled})
+	if merged.Description != "https://example.com/article" {
+		t.Errorf("error: got title %q, expected the url", merged.Description)
+	}
+}
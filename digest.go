@@ -0,0 +1,311 @@
+package pinboard
+
+import (
+	"bytes"
+	"crypto/rand"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"mime/multipart"
+	"mime/quotedprintable"
+	"net/mail"
+	"net/textproto"
+	"sort"
+	"strings"
+	"text/template"
+	"time"
+)
+
+// Period is the span of time a digest covers.
+type Period int
+
+const (
+	// Weekly digests cover Monday to Sunday.
+	Weekly Period = iota
+
+	// Monthly digests cover a calendar month.
+	Monthly
+)
+
+// Range returns the start of the period that contains t and the start
+// of the next one, in UTC.
+func (p Period) Range(t time.Time) (from, to time.Time) {
+	t = t.UTC()
+	day := time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, time.UTC)
+
+	if p == Monthly {
+		from = day.AddDate(0, 0, 1-day.Day())
+		return from, from.AddDate(0, 1, 0)
+	}
+
+	// time.Weekday starts on Sunday; digests start on Monday.
+	from = day.AddDate(0, 0, -((int(day.Weekday()) + 6) % 7))
+	return from, from.AddDate(0, 0, 7)
+}
+
+// Group is a set of bookmarks sharing a tag, domain or date.
+type Group struct {
+	Name  string
+	Posts []*Post
+}
+
+// Count returns the number of bookmarks in the group.
+func (g Group) Count() int {
+	return len(g.Posts)
+}
+
+// untagged is the name of the group of bookmarks without tags.
+const untagged = "untagged"
+
+// GroupByTag groups posts by tag, largest group first. A post with
+// several tags is in several groups.
+func GroupByTag(posts []*Post) []Group {
+	return groupBy(posts, func(p *Post) []string {
+		tags := cleanTags(p.Tags)
+		if len(tags) == 0 {
+			return []string{untagged}
+		}
+		return tags
+	})
+}
+
+// GroupByDomain groups posts by the host of their URL, without any
+// leading "www.", largest group first.
+func GroupByDomain(posts []*Post) []Group {
+	return groupBy(posts, func(p *Post) []string {
+		return []string{strings.TrimPrefix(strings.ToLower(p.Href.Hostname()), "www.")}
+	})
+}
+
+// groupBy puts each post in the groups named by keys, keeping the
+// posts' order within a group. Groups are sorted largest first, then
+// by name.
+func groupBy(posts []*Post, keys func(*Post) []string) []Group {
+	index := make(map[string]int)
+
+	var groups []Group
+	for _, p := range posts {
+		for _, k := range keys(p) {
+			i, ok := index[k]
+			if !ok {
+				i = len(groups)
+				index[k] = i
+				groups = append(groups, Group{Name: k})
+			}
+			groups[i].Posts = append(groups[i].Posts, p)
+		}
+	}
+
+	sort.SliceStable(groups, func(i, j int) bool {
+		if len(groups[i].Posts) != len(groups[j].Posts) {
+			return len(groups[i].Posts) > len(groups[j].Posts)
+		}
+		return groups[i].Name < groups[j].Name
+	})
+
+	return groups
+}
+
+// Digest summarises the bookmarks saved over a span of time.
+type Digest struct {
+	Title string
+
+	// Bookmarks saved from From up to, but not including, To.
+	From time.Time
+	To   time.Time
+
+	// The bookmarks, newest first.
+	Posts []*Post
+
+	ByTag    []Group
+	ByDomain []Group
+}
+
+// FetchDigest fetches the bookmarks saved in the period containing t
+// and builds a digest of them.
+func FetchDigest(period Period, t time.Time) (*Digest, error) {
+	from, to := period.Range(t)
+
+	posts, err := PostsAll(&PostsAllOptions{Fromdt: from, Todt: to})
+	if err != nil {
+		return nil, err
+	}
+
+	title := "Bookmarks for the week of " + from.Format("2 January 2006")
+	if period == Monthly {
+		title = "Bookmarks for " + from.Format("January 2006")
+	}
+
+	return NewDigest(title, posts, from, to), nil
+}
+
+// NewDigest builds a digest of the posts saved from from up to to,
+// ignoring the rest. Use it with a local copy of the account to avoid
+// calling the API.
+func NewDigest(title string, posts []*Post, from, to time.Time) *Digest {
+	d := &Digest{Title: title, From: from, To: to}
+
+	for _, p := range posts {
+		if !p.Time.Before(from) && p.Time.Before(to) {
+			d.Posts = append(d.Posts, p)
+		}
+	}
+
+	sort.SliceStable(d.Posts, func(i, j int) bool {
+		return d.Posts[i].Time.After(d.Posts[j].Time)
+	})
+
+	d.ByTag = GroupByTag(d.Posts)
+	d.ByDomain = GroupByDomain(d.Posts)
+
+	return d
+}
+
+// sections returns the digest's groups as sections.
+func (d *Digest) sections() []section {
+	var sections []section
+
+	for _, g := range []struct {
+		title  string
+		groups []Group
+	}{
+		{"By tag", d.ByTag},
+		{"By domain", d.ByDomain},
+	} {
+		if len(g.groups) == 0 {
+			continue
+		}
+
+		s := section{title: g.title}
+		for _, group := range g.groups {
+			s.sections = append(s.sections, section{
+				title: fmt.Sprintf("%s (%d)", group.Name, group.Count()),
+				posts: group.Posts,
+			})
+		}
+		sections = append(sections, s)
+	}
+
+	return sections
+}
+
+// Write writes the digest in format f.
+func (d *Digest) Write(w io.Writer, f Format) error {
+	title := fmt.Sprintf("%s: %d bookmarks", d.Title, len(d.Posts))
+
+	return writeSections(w, f, title, d.sections())
+}
+
+// WriteTemplate writes the digest using a template, which is executed
+// with the *Digest as its data.
+func (d *Digest) WriteTemplate(w io.Writer, tmpl *template.Template) error {
+	return tmpl.Execute(w, d)
+}
+
+// MboxOptions represents the arguments for writing a digest as a mail
+// message.
+type MboxOptions struct {
+	// Required: sender address, as in "Pinboard <me@example.com>".
+	From string
+
+	// Required: recipient address, or a comma separated list of
+	// them.
+	To string
+
+	// Date of the message. Defaults to now.
+	Date time.Time
+}
+
+// WriteMbox writes the digest as an RFC 5322 message in mbox format,
+// with plain text and HTML alternatives, ready to be appended to a
+// local mail spool.
+func (d *Digest) WriteMbox(w io.Writer, opt *MboxOptions) error {
+	if opt == nil || opt.From == "" || opt.To == "" {
+		return errors.New("error: missing from or to address")
+	}
+
+	// Parsing the addresses also rejects line breaks that would
+	// inject headers.
+	from, err := mail.ParseAddress(opt.From)
+	if err != nil {
+		return fmt.Errorf("error: invalid from address: %v", err)
+	}
+
+	to, err := mail.ParseAddressList(opt.To)
+	if err != nil {
+		return fmt.Errorf("error: invalid to address: %v", err)
+	}
+
+	var recipients []string
+	for _, a := range to {
+		recipients = append(recipients, a.String())
+	}
+
+	date := opt.Date
+	if date.IsZero() {
+		date = time.Now()
+	}
+
+	var msg bytes.Buffer
+	mw := multipart.NewWriter(&msg)
+
+	var id [12]byte
+	rand.Read(id[:])
+
+	title := fmt.Sprintf("%s: %d bookmarks", d.Title, len(d.Posts))
+	fmt.Fprintf(&msg, "From: %s\r\n", from)
+	fmt.Fprintf(&msg, "To: %s\r\n", strings.Join(recipients, ", "))
+	fmt.Fprintf(&msg, "Subject: %s\r\n", mime.QEncoding.Encode("utf-8", title))
+	fmt.Fprintf(&msg, "Date: %s\r\n", date.Format(time.RFC1123Z))
+	fmt.Fprintf(&msg, "Message-ID: <%x@pinboard.digest>\r\n", id)
+	msg.WriteString("MIME-Version: 1.0\r\n")
+	fmt.Fprintf(&msg, "Content-Type: multipart/alternative; boundary=%s\r\n\r\n", mw.Boundary())
+
+	for _, part := range []struct {
+		format      Format
+		contentType string
+	}{
+		{FormatText, "text/plain; charset=utf-8"},
+		{FormatHTML, "text/html; charset=utf-8"},
+	} {
+		pw, err := mw.CreatePart(textproto.MIMEHeader{
+			"Content-Type":              {part.contentType},
+			"Content-Transfer-Encoding": {"quoted-printable"},
+		})
+		if err != nil {
+			return err
+		}
+
+		qw := quotedprintable.NewWriter(pw)
+		err = writeSections(qw, part.format, title, d.sections())
+		if err != nil {
+			return err
+		}
+
+		err = qw.Close()
+		if err != nil {
+			return err
+		}
+	}
+
+	err = mw.Close()
+	if err != nil {
+		return err
+	}
+
+	// Mail spools use bare newlines. Lines starting with "From ",
+	// however many ">" precede it, get one more ">" so they aren't
+	// mistaken for the start of a message (the mboxrd convention).
+	var b strings.Builder
+	fmt.Fprintf(&b, "From %s %s\n", from.Address, date.UTC().Format(time.ANSIC))
+	for _, line := range strings.Split(strings.Replace(msg.String(), "\r\n", "\n", -1), "\n") {
+		if strings.HasPrefix(strings.TrimLeft(line, ">"), "From ") {
+			line = ">" + line
+		}
+		b.WriteString(line + "\n")
+	}
+
+	_, err = io.WriteString(w, b.String())
+	return err
+}
@@ -0,0 +1,174 @@
+package pinboard
+
+import (
+	"bytes"
+	"io/ioutil"
+	"mime"
+	"mime/multipart"
+	"net/mail"
+	"strings"
+	"testing"
+	"text/template"
+	"time"
+)
+
+func TestPeriodRange(t *testing.T) {
+	// A Sunday.
+	day := time.Date(2016, 12, 11, 15, 4, 5, 0, time.UTC)
+
+	from, to := Weekly.Range(day)
+	if !from.Equal(time.Date(2016, 12, 5, 0, 0, 0, 0, time.UTC)) || !to.Equal(time.Date(2016, 12, 12, 0, 0, 0, 0, time.UTC)) {
+		t.Errorf("error: got week %v to %v, expected 5 to 12 December", from, to)
+	}
+
+	from, to = Monthly.Range(day)
+	if !from.Equal(time.Date(2016, 12, 1, 0, 0, 0, 0, time.UTC)) || !to.Equal(time.Date(2017, 1, 1, 0, 0, 0, 0, time.UTC)) {
+		t.Errorf("error: got month %v to %v, expected December", from, to)
+	}
+}
+
+func TestDigest(t *testing.T) {
+	from := time.Date(2010, 12, 6, 0, 0, 0, 0, time.UTC)
+
+	a := testPost("https://www.golang.org/doc", "Go docs", "go", "docs")
+	a.Time = from.AddDate(0, 0, 1)
+	b := testPost("https://golang.org/blog", "Go blog", "go")
+	b.Time = from.AddDate(0, 0, 2)
+	c := testPost("https://example.com/", "Example")
+	c.Time = from.AddDate(0, 0, 3)
+	outside := testPost("https://example.com/old", "Old", "go")
+	outside.Time = from.AddDate(0, 0, -1)
+
+	d := NewDigest("Weekly", []*Post{a, b, c, outside}, from, from.AddDate(0, 0, 7))
+
+	if len(d.Posts) != 3 || d.Posts[0].Description != "Example" {
+		t.Fatalf("error: got %v, expected the 3 posts in range newest first", d.Posts)
+	}
+
+	if d.ByTag[0].Name != "go" || d.ByTag[0].Count() != 2 || len(d.ByTag) != 3 {
+		t.Errorf("error: got tag groups %+v", d.ByTag)
+	}
+
+	if d.ByDomain[0].Name != "golang.org" || d.ByDomain[0].Count() != 2 {
+		t.Errorf("error: got domain groups %+v", d.ByDomain)
+	}
+
+	var buf bytes.Buffer
+	err := d.Write(&buf, FormatMarkdown)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, expected := range []string{"# Weekly: 3 bookmarks", "### go (2)", "### untagged (1)", "### golang.org (2)"} {
+		if !strings.Contains(buf.String(), expected) {
+			t.Errorf("error: expected %q in digest:\n%s", expected, buf.String())
+		}
+	}
+
+	tmpl := template.Must(template.New("digest").Parse(
+		`{{range .ByDomain}}{{.Name}}={{.Count}};{{end}}`))
+	buf.Reset()
+	err = d.WriteTemplate(&buf, tmpl)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if buf.String() != "golang.org=2;example.com=1;" {
+		t.Errorf("error: got %q from template", buf.String())
+	}
+}
+
+func TestGroupByTagUntagged(t *testing.T) {
+	groups := GroupByTag([]*Post{
+		apiPost("https://golang.org/", "Go", "go"),
+		apiPost("https://example.com/", "Example", ""),
+	})
+
+	if len(groups) != 2 || groups[1].Name != "untagged" || groups[1].Posts[0].Description != "Example" {
+		t.Errorf("error: got groups %+v, expected go and untagged", groups)
+	}
+}
+
+func TestDigestMbox(t *testing.T) {
+	from := time.Date(2010, 12, 6, 0, 0, 0, 0, time.UTC)
+
+	p := testPost("https://www.golang.org/doc", "Go docs", "go", "docs")
+	p.Extended = []byte("From the Go team.")
+	p.Time = from.AddDate(0, 0, 1)
+
+	d := NewDigest("Weekly", []*Post{p}, from, from.AddDate(0, 0, 7))
+	date := time.Date(2010, 12, 13, 8, 0, 0, 0, time.UTC)
+
+	var buf bytes.Buffer
+	err := d.WriteMbox(&buf, &MboxOptions{
+		From: "Pinboard <digest@example.com>",
+		To:   "me@example.com",
+		Date: date,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	mbox := buf.String()
+	if !strings.HasPrefix(mbox, "From digest@example.com Mon Dec 13 08:00:00 2010\n") {
+		t.Errorf("error: got mbox separator %q", strings.SplitN(mbox, "\n", 2)[0])
+	}
+
+	if !strings.HasSuffix(mbox, "\n\n") {
+		t.Error("error: expected message to end with a blank line")
+	}
+
+	if strings.Contains(mbox, "\nFrom the Go team") {
+		t.Error("error: expected From line in body to be escaped")
+	}
+
+	msg, err := mail.ReadMessage(strings.NewReader(mbox[strings.Index(mbox, "\n")+1:]))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if msg.Header.Get("Subject") != "Weekly: 1 bookmarks" {
+		t.Errorf("error: got subject %q", msg.Header.Get("Subject"))
+	}
+
+	_, params, err := mime.ParseMediaType(msg.Header.Get("Content-Type"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	mr := multipart.NewReader(msg.Body, params["boundary"])
+	var types []string
+	for {
+		part, err := mr.NextPart()
+		if err != nil {
+			break
+		}
+		body, _ := ioutil.ReadAll(part)
+		types = append(types, part.Header.Get("Content-Type"))
+
+		if !strings.Contains(string(body), "Go docs") {
+			t.Errorf("error: expected post in %s part:\n%s", part.Header.Get("Content-Type"), body)
+		}
+	}
+
+	if len(types) != 2 {
+		t.Errorf("error: got parts %v, expected text and html", types)
+	}
+
+	if msg.Header.Get("From") != `"Pinboard" <digest@example.com>` || msg.Header.Get("To") != "<me@example.com>" {
+		t.Errorf("error: got from %q and to %q", msg.Header.Get("From"), msg.Header.Get("To"))
+	}
+
+	for _, opt := range []*MboxOptions{
+		nil,
+		{From: "a@example.com"},
+		{From: "a@example.com\r\nBcc: b@example.com", To: "me@example.com"},
+		{From: "a@example.com", To: "me@example.com\nBcc: b@example.com"},
+		{From: "not an address", To: "me@example.com"},
+	} {
+		err = d.WriteMbox(&buf, opt)
+		if err == nil {
+			t.Errorf("error: expected an error for options %+v", opt)
+		}
+	}
+}
@@ -0,0 +1,275 @@
+package pinboard
+
+import (
+	"archive/zip"
+	"crypto/sha1"
+	"errors"
+	"fmt"
+	"html"
+	"io"
+	"regexp"
+	"strings"
+	"time"
+)
+
+// EPUBOptions describes the book written by WriteEPUB.
+type EPUBOptions struct {
+	// Title of the book. Defaults to "Pinboard: Read Later".
+	Title string
+
+	// Author of the book. Optional.
+	Author string
+
+	// Language of the book as a BCP 47 tag. Defaults to "en".
+	Language string
+
+	// Time the book was last modified. Defaults to now.
+	Modified time.Time
+}
+
+// EPUBChapter is a bookmark and the article it points to. Article
+// may be nil, in which case the chapter only holds the bookmark
+// details.
+type EPUBChapter struct {
+	Post    *Post
+	Article *Article
+}
+
+// epubImage matches the images in Article.HTML. They are dropped
+// since EPUB readers won't load remote images.
+var epubImage = regexp.MustCompile(`<img [^>]*/>`)
+
+// WriteEPUB writes an EPUB 3 book with a chapter for each article,
+// in order, and a table of contents.
+func WriteEPUB(w io.Writer, chapters []EPUBChapter, opt *EPUBOptions) error {
+	if len(chapters) == 0 {
+		return errors.New("error: no chapters")
+	}
+
+	if opt == nil {
+		opt = &EPUBOptions{}
+	}
+
+	title := firstNonEmpty(opt.Title, "Pinboard: Read Later")
+	lang := firstNonEmpty(opt.Language, "en")
+	modified := opt.Modified
+	if modified.IsZero() {
+		modified = time.Now()
+	}
+
+	z := zip.NewWriter(w)
+
+	// The mimetype file has to come first and be stored uncompressed
+	// so readers can identify the file by its first bytes.
+	f, err := z.CreateHeader(&zip.FileHeader{Name: "mimetype", Method: zip.Store})
+	if err != nil {
+		return err
+	}
+	_, err = io.WriteString(f, "application/epub+zip")
+	if err != nil {
+		return err
+	}
+
+	files := []struct {
+		name, data string
+	}{
+		{"META-INF/container.xml", epubContainer},
+		{"OEBPS/content.opf", epubPackage(chapters, title, opt.Author, lang, modified)},
+		{"OEBPS/nav.xhtml", epubNav(chapters, title, lang)},
+		{"OEBPS/style.css", epubStyle},
+	}
+	for i, c := range chapters {
+		files = append(files, struct{ name, data string }{
+			"OEBPS/" + epubChapterFile(i), epubChapter(c, lang),
+		})
+	}
+
+	for _, file := range files {
+		f, err := z.Create(file.name)
+		if err != nil {
+			return err
+		}
+
+		_, err = io.WriteString(f, file.data)
+		if err != nil {
+			return err
+		}
+	}
+
+	return z.Close()
+}
+
+// WriteEPUB writes an EPUB book of the posts marked to read later,
+// using the stored articles. Call Sync first to download articles
+// that aren't stored yet; posts without one get a chapter holding
+// only the bookmark details.
+func (rl *ReadingList) WriteEPUB(w io.Writer, posts []*Post, opt *EPUBOptions) error {
+	var chapters []EPUBChapter
+	for _, p := range posts {
+		if !p.Toread {
+			continue
+		}
+
+		a, err := rl.Get(p)
+		if err != nil {
+			return err
+		}
+
+		chapters = append(chapters, EPUBChapter{Post: p, Article: a})
+	}
+
+	return WriteEPUB(w, chapters, opt)
+}
+
+const epubContainer = `<?xml version="1.0" encoding="UTF-8"?>
+<container version="1.0" xmlns="urn:oasis:names:tc:opendocument:xmlns:container">
+  <rootfiles>
+    <rootfile full-path="OEBPS/content.opf" media-type="application/oebps-package+xml"/>
+  </rootfiles>
+</container>
+`
+
+const epubStyle = `body { font-family: serif; line-height: 1.4; }
+h1 { font-size: 1.4em; }
+.bookmark { font-family: sans-serif; font-size: 0.8em; color: #555; }
+.bookmark a { word-break: break-all; }
+pre { white-space: pre-wrap; }
+`
+
+// epubChapterFile returns the file name of the i'th chapter.
+func epubChapterFile(i int) string {
+	return fmt.Sprintf("chapter-%03d.xhtml", i+1)
+}
+
+// epubTitle returns the title of a chapter.
+func epubTitle(c EPUBChapter) string {
+	var articleTitle string
+	if c.Article != nil {
+		articleTitle = c.Article.Title
+	}
+
+	return firstNonEmpty(c.Post.Description, articleTitle, c.Post.Href.String())
+}
+
+// epubIdentifier returns a name based UUID for the book, so writing
+// the same chapters again gives the same identifier.
+func epubIdentifier(chapters []EPUBChapter, title string) string {
+	h := sha1.New()
+	io.WriteString(h, title)
+	for _, c := range chapters {
+		io.WriteString(h, postHash(c.Post))
+	}
+
+	b := h.Sum(nil)
+	b[6] = b[6]&0x0f | 0x50
+	b[8] = b[8]&0x3f | 0x80
+
+	return fmt.Sprintf("urn:uuid:%x-%x-%x-%x-%x", b[0:4], b[4:6], b[6:8], b[8:10], b[10:16])
+}
+
+// epubPackage returns the package document listing the book's
+// metadata and files.
+func epubPackage(chapters []EPUBChapter, title, author, lang string, modified time.Time) string {
+	var b strings.Builder
+
+	b.WriteString(`<?xml version="1.0" encoding="UTF-8"?>` + "\n")
+	fmt.Fprintf(&b, `<package xmlns="http://www.idpf.org/2007/opf" version="3.0" unique-identifier="book-id" xml:lang="%s">`+"\n", html.EscapeString(lang))
+	b.WriteString(`  <metadata xmlns:dc="http://purl.org/dc/elements/1.1/">` + "\n")
+	fmt.Fprintf(&b, "    <dc:identifier id=\"book-id\">%s</dc:identifier>\n", epubIdentifier(chapters, title))
+	fmt.Fprintf(&b, "    <dc:title>%s</dc:title>\n", html.EscapeString(title))
+	fmt.Fprintf(&b, "    <dc:language>%s</dc:language>\n", html.EscapeString(lang))
+	if author != "" {
+		fmt.Fprintf(&b, "    <dc:creator>%s</dc:creator>\n", html.EscapeString(author))
+	}
+	fmt.Fprintf(&b, "    <meta property=\"dcterms:modified\">%s</meta>\n", modified.UTC().Format("2006-01-02T15:04:05Z"))
+	b.WriteString("  </metadata>\n")
+
+	b.WriteString("  <manifest>\n")
+	b.WriteString(`    <item id="nav" href="nav.xhtml" media-type="application/xhtml+xml" properties="nav"/>` + "\n")
+	b.WriteString(`    <item id="style" href="style.css" media-type="text/css"/>` + "\n")
+	for i := range chapters {
+		fmt.Fprintf(&b, "    <item id=\"chapter-%d\" href=\"%s\" media-type=\"application/xhtml+xml\"/>\n", i+1, epubChapterFile(i))
+	}
+	b.WriteString("  </manifest>\n")
+
+	b.WriteString("  <spine>\n")
+	b.WriteString(`    <itemref idref="nav"/>` + "\n")
+	for i := range chapters {
+		fmt.Fprintf(&b, "    <itemref idref=\"chapter-%d\"/>\n", i+1)
+	}
+	b.WriteString("  </spine>\n")
+	b.WriteString("</package>\n")
+
+	return b.String()
+}
+
+// epubDocument wraps body in an XHTML content document.
+func epubDocument(title, lang, body string) string {
+	var b strings.Builder
+
+	b.WriteString(`<?xml version="1.0" encoding="UTF-8"?>` + "\n")
+	b.WriteString("<!DOCTYPE html>\n")
+	fmt.Fprintf(&b, `<html xmlns="http://www.w3.org/1999/xhtml" xmlns:epub="http://www.idpf.org/2007/ops" xml:lang="%[1]s" lang="%[1]s">`+"\n", html.EscapeString(lang))
+	b.WriteString("<head>\n")
+	b.WriteString(`<meta charset="UTF-8"/>` + "\n")
+	fmt.Fprintf(&b, "<title>%s</title>\n", html.EscapeString(title))
+	b.WriteString(`<link rel="stylesheet" type="text/css" href="style.css"/>` + "\n")
+	b.WriteString("</head>\n<body>\n")
+	b.WriteString(body)
+	b.WriteString("</body>\n</html>\n")
+
+	return b.String()
+}
+
+// epubNav returns the navigation document holding the table of
+// contents.
+func epubNav(chapters []EPUBChapter, title, lang string) string {
+	var b strings.Builder
+
+	b.WriteString(`<nav epub:type="toc" id="toc">` + "\n")
+	fmt.Fprintf(&b, "<h1>%s</h1>\n<ol>\n", html.EscapeString(title))
+	for i, c := range chapters {
+		fmt.Fprintf(&b, "<li><a href=\"%s\">%s</a></li>\n", epubChapterFile(i), html.EscapeString(epubTitle(c)))
+	}
+	b.WriteString("</ol>\n</nav>\n")
+
+	return epubDocument(title, lang, b.String())
+}
+
+// epubChapter returns the content document for a chapter.
+func epubChapter(c EPUBChapter, lang string) string {
+	title := epubTitle(c)
+	href := html.EscapeString(c.Post.Href.String())
+
+	var b strings.Builder
+
+	b.WriteString(`<section epub:type="chapter">` + "\n")
+	fmt.Fprintf(&b, "<h1>%s</h1>\n", html.EscapeString(title))
+	b.WriteString(`<div class="bookmark">` + "\n")
+	fmt.Fprintf(&b, "<p><a href=\"%s\">%s</a></p>\n", href, href)
+	if tags := cleanTags(c.Post.Tags); len(tags) > 0 {
+		fmt.Fprintf(&b, "<p>Tags: %s</p>\n", html.EscapeString(strings.Join(tags, ", ")))
+	}
+
+	saved := c.Post.Time
+	if saved.IsZero() && c.Article != nil {
+		saved = c.Article.Saved
+	}
+	if !saved.IsZero() {
+		fmt.Fprintf(&b, "<p>Saved %s</p>\n", saved.Format("2 January 2006"))
+	}
+	if c.Article != nil && c.Article.ReadingMinutes > 0 {
+		fmt.Fprintf(&b, "<p>%d words, about %d min</p>\n", c.Article.WordCount, c.Article.ReadingMinutes)
+	}
+	b.WriteString("</div>\n")
+
+	if c.Article != nil {
+		b.WriteString(epubImage.ReplaceAllString(c.Article.HTML, ""))
+		b.WriteString("\n")
+	} else {
+		b.WriteString("<p>The article hasn't been downloaded.</p>\n")
+	}
+	b.WriteString("</section>\n")
+
+	return epubDocument(title, lang, b.String())
+}
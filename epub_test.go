@@ -0,0 +1,116 @@
+package pinboard
+
+import (
+	"archive/zip"
+	"bytes"
+	"encoding/xml"
+	"io"
+	"io/ioutil"
+	"net/url"
+	"strings"
+	"testing"
+)
+
+func TestWriteEPUB(t *testing.T) {
+	base, _ := url.Parse("https://example.com/post")
+	article := ExtractArticle(base, []byte(`<html><body><article>
+		<p>First line<br>second line with an <img src="/a.png" alt="image"> inline.</p>
+		<p>Fish &amp; chips &lt;3 and <a href="/more">more</a> words to read.</p>
+	</article></body></html>`), "text/html; charset=utf-8")
+
+	read := testPost("https://example.com/post", "Fish & Chips", "food", "uk")
+	unread := testPost("https://example.com/later", "Later")
+
+	var buf bytes.Buffer
+	err := WriteEPUB(&buf, []EPUBChapter{
+		{Post: read, Article: article},
+		{Post: unread},
+	}, &EPUBOptions{Title: "Queue"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	z, err := zip.NewReader(bytes.NewReader(buf.Bytes()), int64(buf.Len()))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	first := z.File[0]
+	if first.Name != "mimetype" || first.Method != zip.Store {
+		t.Errorf("error: got %v (method %v), expected stored mimetype first", first.Name, first.Method)
+	}
+
+	files := make(map[string]string)
+	for _, f := range z.File {
+		r, err := f.Open()
+		if err != nil {
+			t.Fatal(err)
+		}
+		data, _ := ioutil.ReadAll(r)
+		r.Close()
+		files[f.Name] = string(data)
+	}
+
+	if files["mimetype"] != "application/epub+zip" {
+		t.Errorf("error: got mimetype %q", files["mimetype"])
+	}
+
+	for _, name := range []string{
+		"META-INF/container.xml",
+		"OEBPS/content.opf",
+		"OEBPS/nav.xhtml",
+		"OEBPS/chapter-001.xhtml",
+		"OEBPS/chapter-002.xhtml",
+	} {
+		data, ok := files[name]
+		if !ok {
+			t.Errorf("error: missing %s", name)
+			continue
+		}
+
+		d := xml.NewDecoder(strings.NewReader(data))
+		for {
+			_, err := d.Token()
+			if err == io.EOF {
+				break
+			}
+			if err != nil {
+				t.Errorf("error: %s isn't well formed: %s", name, err)
+				break
+			}
+		}
+	}
+
+	chapter := files["OEBPS/chapter-001.xhtml"]
+	for _, expected := range []string{
+		"<h1>Fish &amp; Chips</h1>",
+		"https://example.com/post",
+		"Tags: food, uk",
+		"Saved 11 December 2010",
+		"Fish &amp; chips &lt;3",
+	} {
+		if !strings.Contains(chapter, expected) {
+			t.Errorf("error: expected %q in chapter:\n%s", expected, chapter)
+		}
+	}
+
+	if strings.Contains(chapter, "<img") {
+		t.Errorf("error: expected remote images to be dropped:\n%s", chapter)
+	}
+
+	if !strings.Contains(files["OEBPS/nav.xhtml"], `<a href="chapter-002.xhtml">Later</a>`) {
+		t.Errorf("error: expected chapter 2 in table of contents:\n%s", files["OEBPS/nav.xhtml"])
+	}
+
+	err = WriteEPUB(ioutil.Discard, nil, nil)
+	if err == nil {
+		t.Error("error: expected no chapters error")
+	}
+}
+
+func TestEPUBChapterUntagged(t *testing.T) {
+	chapter := epubChapter(EPUBChapter{Post: apiPost("https://golang.org/", "Go", "")}, "en")
+	if strings.Contains(chapter, "Tags:") {
+		t.Errorf("error: expected no tags line in %s", chapter)
+	}
+}
@@ -0,0 +1,295 @@
+package pinboard
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"text/template"
+)
+
+// Grouping is how exported bookmarks are grouped.
+type Grouping int
+
+const (
+	// NoGrouping exports bookmarks in the order given.
+	NoGrouping Grouping = iota
+
+	// GroupTag groups bookmarks by tag, largest group first. A
+	// bookmark with several tags is in several groups.
+	GroupTag
+
+	// GroupMonth groups bookmarks by the month they were saved in,
+	// most recent first.
+	GroupMonth
+
+	// GroupDomain groups bookmarks by domain, largest group first.
+	GroupDomain
+)
+
+// GroupByMonth groups posts by the month they were saved in, as
+// "2006-01", most recent first.
+func GroupByMonth(posts []*Post) []Group {
+	groups := groupBy(posts, func(p *Post) []string {
+		return []string{p.Time.UTC().Format("2006-01")}
+	})
+
+	sort.SliceStable(groups, func(i, j int) bool {
+		return groups[i].Name > groups[j].Name
+	})
+
+	return groups
+}
+
+// group groups posts as g says. NoGrouping gives a single group
+// without a name.
+func (g Grouping) group(posts []*Post) []Group {
+	switch g {
+	case GroupTag:
+		return GroupByTag(posts)
+	case GroupMonth:
+		return GroupByMonth(posts)
+	case GroupDomain:
+		return GroupByDomain(posts)
+	}
+
+	return []Group{{Posts: posts}}
+}
+
+// ExportOptions represents the optional arguments for exporting
+// bookmarks.
+type ExportOptions struct {
+	// Title of the document. Defaults to "Bookmarks".
+	Title string
+
+	// How bookmarks are grouped. Defaults to NoGrouping.
+	Group Grouping
+}
+
+// ExportData is the data templates passed to WritePostsTemplate are
+// executed with.
+type ExportData struct {
+	Title  string
+	Groups []Group
+	Posts  []*Post
+}
+
+// exportData returns the data for exporting posts.
+func exportData(posts []*Post, opt *ExportOptions) *ExportData {
+	if opt == nil {
+		opt = &ExportOptions{}
+	}
+
+	return &ExportData{
+		Title:  firstNonEmpty(opt.Title, "Bookmarks"),
+		Groups: opt.Group.group(posts),
+		Posts:  posts,
+	}
+}
+
+// WritePostsTemplate writes posts using a template, which is executed
+// with an *ExportData.
+func WritePostsTemplate(w io.Writer, posts []*Post, opt *ExportOptions, tmpl *template.Template) error {
+	return tmpl.Execute(w, exportData(posts, opt))
+}
+
+// WriteMarkdown writes posts as a Markdown document with a list of
+// bookmarks under a heading for each group.
+func WriteMarkdown(w io.Writer, posts []*Post, opt *ExportOptions) error {
+	data := exportData(posts, opt)
+
+	var sections []section
+	for _, g := range data.Groups {
+		sections = append(sections, section{title: g.Name, posts: g.Posts})
+	}
+
+	return writeSections(w, FormatMarkdown, data.Title, sections)
+}
+
+// orgTagChars matches the characters Org-mode doesn't allow in tags.
+var orgTagChars = regexp.MustCompile(`[^\p{L}\p{N}_@#%]+`)
+
+// WriteOrg writes posts as an Org-mode document. Each bookmark is a
+// heading linking to the page, with its tags as Org tags, its time,
+// shared and toread flags as properties, and its extended description
+// as the body. Groups are headings one level up.
+func WriteOrg(w io.Writer, posts []*Post, opt *ExportOptions) error {
+	data := exportData(posts, opt)
+
+	bw := bufio.NewWriter(w)
+	fmt.Fprintf(bw, "#+TITLE: %s\n", data.Title)
+
+	for _, g := range data.Groups {
+		level := 1
+		if g.Name != "" {
+			fmt.Fprintf(bw, "\n* %s\n", g.Name)
+			level = 2
+		}
+
+		for _, p := range g.Posts {
+			writeOrgPost(bw, p, level)
+		}
+	}
+
+	return bw.Flush()
+}
+
+// writeOrgPost writes a bookmark as an Org heading.
+func writeOrgPost(w *bufio.Writer, p *Post, level int) {
+	// Org links can't hold brackets in their description.
+	title := strings.NewReplacer("[", "{", "]", "}").Replace(firstNonEmpty(p.Description, p.Href.String()))
+	fmt.Fprintf(w, "\n%s [[%s][%s]]", strings.Repeat("*", level), p.Href, title)
+
+	var tags []string
+	for _, t := range p.Tags {
+		if t = orgTagChars.ReplaceAllString(t, "_"); t != "" {
+			tags = append(tags, t)
+		}
+	}
+	if len(tags) > 0 {
+		fmt.Fprintf(w, " :%s:", strings.Join(tags, ":"))
+	}
+	w.WriteString("\n")
+
+	w.WriteString(":PROPERTIES:\n")
+	fmt.Fprintf(w, ":URL: %s\n", p.Href)
+	fmt.Fprintf(w, ":TIME: [%s]\n", p.Time.UTC().Format("2006-01-02 Mon 15:04"))
+	fmt.Fprintf(w, ":SHARED: %s\n", yesNo(p.Shared))
+	fmt.Fprintf(w, ":TOREAD: %s\n", yesNo(p.Toread))
+	if len(p.Hash) > 0 {
+		fmt.Fprintf(w, ":HASH: %s\n", p.Hash)
+	}
+	w.WriteString(":END:\n")
+
+	extended := strings.TrimSpace(string(p.Extended))
+	if extended == "" {
+		return
+	}
+
+	for _, line := range strings.Split(extended, "\n") {
+		// A line starting with a star would be read as a heading.
+		if strings.HasPrefix(line, "*") {
+			line = " " + line
+		}
+		w.WriteString(line + "\n")
+	}
+}
+
+// yesNo returns b the way Pinboard writes booleans.
+func yesNo(b bool) string {
+	if b {
+		return "yes"
+	}
+
+	return "no"
+}
+
+// WriteMarkdownFiles writes each post to its own Markdown file in dir,
+// with the bookmark's details as YAML front matter and its extended
+// description as the body, and returns the paths written. Grouped
+// posts go in a subdirectory named after their group; with GroupTag,
+// that of their first tag so that each bookmark is written once, or
+// "untagged" if none of its tags can be used as a directory name.
+func WriteMarkdownFiles(dir string, posts []*Post, opt *ExportOptions) ([]string, error) {
+	if opt == nil {
+		opt = &ExportOptions{}
+	}
+
+	var paths []string
+	for _, p := range posts {
+		sub := ""
+		switch opt.Group {
+		case GroupTag:
+			sub = untagged
+			for _, t := range cleanTags(p.Tags) {
+				if slug(t) != "" {
+					sub = t
+					break
+				}
+			}
+		case GroupMonth, GroupDomain:
+			sub = opt.Group.group([]*Post{p})[0].Name
+		}
+
+		path := filepath.Join(dir, slug(sub), markdownFileName(p))
+		err := os.MkdirAll(filepath.Dir(path), 0755)
+		if err != nil {
+			return paths, err
+		}
+
+		err = ioutil.WriteFile(path, markdownFile(p), 0644)
+		if err != nil {
+			return paths, err
+		}
+
+		paths = append(paths, path)
+	}
+
+	return paths, nil
+}
+
+// slugChars matches runs of characters left out of file names.
+var slugChars = regexp.MustCompile(`[^\p{L}\p{N}]+`)
+
+// slug returns s lower cased with anything but letters and digits
+// replaced by dashes.
+func slug(s string) string {
+	return strings.Trim(slugChars.ReplaceAllString(strings.ToLower(s), "-"), "-")
+}
+
+// markdownFileName returns the file name for a bookmark, made from its
+// title and hash so that it is both readable and unique.
+func markdownFileName(p *Post) string {
+	name := []rune(slug(p.Description))
+	if len(name) > 60 {
+		name = []rune(strings.TrimRight(string(name[:60]), "-"))
+	}
+
+	hash := postHash(p)
+	if len(name) == 0 {
+		return hash[:8] + ".md"
+	}
+
+	return string(name) + "-" + hash[:8] + ".md"
+}
+
+// markdownFile returns a bookmark as a Markdown file with YAML front
+// matter. Strings are written as JSON strings, which YAML reads as
+// double quoted scalars.
+func markdownFile(p *Post) []byte {
+	quote := func(s string) string {
+		b, _ := json.Marshal(s)
+		return string(b)
+	}
+
+	var b strings.Builder
+	b.WriteString("---\n")
+	fmt.Fprintf(&b, "title: %s\n", quote(p.Description))
+	fmt.Fprintf(&b, "url: %s\n", quote(p.Href.String()))
+	tags := cleanTags(p.Tags)
+	b.WriteString("tags:")
+	if len(tags) == 0 {
+		b.WriteString(" []")
+	}
+	b.WriteString("\n")
+	for _, t := range tags {
+		fmt.Fprintf(&b, "  - %s\n", quote(t))
+	}
+	fmt.Fprintf(&b, "time: %s\n", p.Time.UTC().Format("2006-01-02T15:04:05Z"))
+	fmt.Fprintf(&b, "shared: %t\n", p.Shared)
+	fmt.Fprintf(&b, "toread: %t\n", p.Toread)
+	fmt.Fprintf(&b, "hash: %s\n", quote(postHash(p)))
+	b.WriteString("---\n")
+
+	if extended := strings.TrimSpace(string(p.Extended)); extended != "" {
+		b.WriteString("\n" + extended + "\n")
+	}
+
+	return []byte(b.String())
+}
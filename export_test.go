@@ -0,0 +1,189 @@
+package pinboard
+
+import (
+	"bytes"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"text/template"
+)
+
+func TestGroupByMonth(t *testing.T) {
+	a := testPost("https://golang.org/", "Go")
+	b := testPost("https://www.golang.org/blog", "Go Blog")
+	b.Time = b.Time.AddDate(0, 1, 0)
+	c := testPost("https://example.com/", "Example")
+
+	groups := GroupByMonth([]*Post{a, b, c})
+	if len(groups) != 2 || groups[0].Name != "2011-01" || groups[1].Count() != 2 {
+		t.Errorf("error: got %+v", groups)
+	}
+}
+
+func TestWriteExport(t *testing.T) {
+	a := testPost("https://golang.org/", "The [Go] Language", "go", "c++")
+	a.Extended = []byte("Fast.\n* not a heading")
+	a.Shared = true
+	b := testPost("https://www.golang.org/blog", "Go Blog", "go")
+	b.Time = b.Time.AddDate(0, 1, 0)
+	c := testPost("https://example.com/", "Example")
+	c.Toread = true
+	posts := []*Post{a, b, c}
+
+	tests := []struct {
+		name     string
+		write    func(io.Writer, []*Post, *ExportOptions) error
+		opt      *ExportOptions
+		expected []string
+	}{
+		{
+			"org",
+			WriteOrg,
+			&ExportOptions{Group: GroupTag},
+			[]string{
+				"#+TITLE: Bookmarks\n",
+				"\n* go\n",
+				"\n** [[https://golang.org/][The {Go} Language]] :go:c_:\n",
+				":TIME: [2010-12-11 Sat 19:48]\n",
+				":SHARED: yes\n:TOREAD: no\n",
+				"Fast.\n * not a heading\n",
+				"\n* untagged\n",
+			},
+		},
+		{
+			"markdown",
+			WriteMarkdown,
+			&ExportOptions{Title: "Links", Group: GroupDomain},
+			[]string{
+				"# Links\n",
+				"## golang.org\n",
+				"- [The \\[Go\\] Language](<https://golang.org/>)",
+			},
+		},
+	}
+
+	for _, test := range tests {
+		var buf bytes.Buffer
+		err := test.write(&buf, posts, test.opt)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		for _, expected := range test.expected {
+			if !strings.Contains(buf.String(), expected) {
+				t.Errorf("error: %s: expected %q in:\n%s", test.name, expected, buf.String())
+			}
+		}
+	}
+}
+
+func TestWritePostsTemplate(t *testing.T) {
+	b := testPost("https://www.golang.org/blog", "Go Blog", "go")
+	b.Time = b.Time.AddDate(0, 1, 0)
+	c := testPost("https://example.com/", "Example")
+
+	var buf bytes.Buffer
+	tmpl := template.Must(template.New("export").Parse(
+		`{{range .Groups}}{{.Name}}:{{range .Posts}} {{.Description}}{{end}};{{end}}`))
+	err := WritePostsTemplate(&buf, []*Post{b, c}, &ExportOptions{Group: GroupMonth}, tmpl)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if buf.String() != "2011-01: Go Blog;2010-12: Example;" {
+		t.Errorf("error: got %q from template", buf.String())
+	}
+}
+
+func TestWriteMarkdownFiles(t *testing.T) {
+	dir, err := ioutil.TempDir("", "export")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	a := testPost("https://golang.org/", "The [Go] Language", "go", "c++")
+	a.Extended = []byte("Fast.\n* not a heading")
+	a.Shared = true
+	b := testPost("https://www.golang.org/blog", "Go Blog", "go")
+	c := testPost("https://example.com/", "Example")
+
+	paths, err := WriteMarkdownFiles(dir, []*Post{a, b, c}, &ExportOptions{Group: GroupTag})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(paths) != 3 {
+		t.Fatalf("error: got %v files, expected 3", len(paths))
+	}
+
+	expected := filepath.Join(dir, "go", "the-go-language-12fc1b9d.md")
+	if paths[0] != expected {
+		t.Errorf("error: got %v, expected %v", paths[0], expected)
+	}
+
+	if filepath.Base(filepath.Dir(paths[2])) != "untagged" {
+		t.Errorf("error: got %v, expected untagged directory", paths[2])
+	}
+
+	data, err := ioutil.ReadFile(paths[0])
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := `---
+title: "The [Go] Language"
+url: "https://golang.org/"
+tags:
+  - "go"
+  - "c++"
+time: 2010-12-11T19:48:02Z
+shared: true
+toread: false
+hash: "12fc1b9dc2d516422a91f6aad8e53696"
+---
+
+Fast.
+* not a heading
+`
+	if string(data) != want {
+		t.Errorf("error: got\n%s\nexpected\n%s", data, want)
+	}
+}
+
+func TestWriteMarkdownFilesUntagged(t *testing.T) {
+	dir, err := ioutil.TempDir("", "export")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	posts := []*Post{
+		apiPost("https://golang.org/", "Go", ""),
+		apiPost("https://isocpp.org/", "C++", "++ cpp"),
+		apiPost("https://example.com/", "Example", "++"),
+	}
+
+	paths, err := WriteMarkdownFiles(dir, posts, &ExportOptions{Group: GroupTag})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for i, expected := range []string{"untagged", "cpp", "untagged"} {
+		if got := filepath.Base(filepath.Dir(paths[i])); got != expected {
+			t.Errorf("error: got directory %v for %v, expected %v", got, posts[i].Href, expected)
+		}
+	}
+
+	data, err := ioutil.ReadFile(paths[0])
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !strings.Contains(string(data), "tags: []\n") {
+		t.Errorf("error: expected empty tags in front matter:\n%s", data)
+	}
+}
@@ -0,0 +1,308 @@
+package pinboard
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// DefaultFeedsURL is where Pinboard serves its RSS and JSON feeds.
+const DefaultFeedsURL = "https://feeds.pinboard.in"
+
+// Feeds reads Pinboard's RSS and JSON feeds, which include bookmarks
+// the API doesn't give access to, such as the user's network and
+// popular bookmarks.
+//
+// https://pinboard.in/howto/#rss
+type Feeds struct {
+	// Fetcher used for downloads. Optional.
+	Fetcher *Fetcher
+
+	// URL the feeds are served from. Defaults to DefaultFeedsURL.
+	BaseURL string
+
+	// User whose feeds to read. Required for the user, private,
+	// unread and network feeds.
+	User string
+
+	// Secret RSS key returned by UserSecret. Required for the
+	// private, unread and network feeds.
+	Secret string
+
+	// Read the JSON version of feeds instead of RSS.
+	JSON bool
+
+	// Number of bookmarks to return. Pinboard's default is 50 and
+	// the maximum 400.
+	Count int
+}
+
+// url returns the URL of the feed with the given path segments.
+func (f *Feeds) url(segments ...string) string {
+	format := "rss"
+	if f.JSON {
+		format = "json"
+	}
+
+	var b strings.Builder
+	b.WriteString(strings.TrimSuffix(firstNonEmpty(f.BaseURL, DefaultFeedsURL), "/"))
+	b.WriteString("/" + format + "/")
+	for _, s := range segments {
+		b.WriteString(s + "/")
+	}
+
+	if f.Count > 0 {
+		fmt.Fprintf(&b, "?count=%d", f.Count)
+	}
+
+	return b.String()
+}
+
+// tagSegments returns the path segments selecting tags.
+func tagSegments(tags []string) []string {
+	var segments []string
+	for _, t := range tags {
+		segments = append(segments, "t:"+url.PathEscape(t))
+	}
+
+	return segments
+}
+
+// UserURL returns the URL of the user's public bookmarks, optionally
+// filtered by up to three tags.
+func (f *Feeds) UserURL(tags ...string) string {
+	return f.url(append([]string{"u:" + url.PathEscape(f.User)}, tagSegments(tags)...)...)
+}
+
+// PrivateURL returns the URL of all the user's bookmarks, private
+// ones included, optionally filtered by up to three tags.
+func (f *Feeds) PrivateURL(tags ...string) string {
+	return f.url(append([]string{"secret:" + url.PathEscape(f.Secret), "u:" + url.PathEscape(f.User)}, tagSegments(tags)...)...)
+}
+
+// UnreadURL returns the URL of the user's bookmarks marked to read
+// later.
+func (f *Feeds) UnreadURL() string {
+	return f.url("secret:"+url.PathEscape(f.Secret), "u:"+url.PathEscape(f.User), "toread")
+}
+
+// NetworkURL returns the URL of the bookmarks saved by the users in
+// the user's network.
+func (f *Feeds) NetworkURL() string {
+	return f.url("secret:"+url.PathEscape(f.Secret), "u:"+url.PathEscape(f.User), "network")
+}
+
+// TagURL returns the URL of everyone's public bookmarks with all of
+// the given tags.
+func (f *Feeds) TagURL(tags ...string) string {
+	return f.url(tagSegments(tags)...)
+}
+
+// PopularURL returns the URL of the popular bookmarks.
+func (f *Feeds) PopularURL() string {
+	return f.url("popular")
+}
+
+// RecentURL returns the URL of everyone's most recent public
+// bookmarks.
+func (f *Feeds) RecentURL() string {
+	return f.url("recent")
+}
+
+// UserPosts returns the user's public bookmarks, optionally filtered
+// by up to three tags.
+func (f *Feeds) UserPosts(tags ...string) ([]*Post, error) {
+	if f.User == "" {
+		return nil, errors.New("error: missing user")
+	}
+
+	return f.FetchFeed(f.UserURL(tags...))
+}
+
+// PrivatePosts returns all the user's bookmarks, private ones
+// included, optionally filtered by up to three tags.
+func (f *Feeds) PrivatePosts(tags ...string) ([]*Post, error) {
+	if f.User == "" || f.Secret == "" {
+		return nil, errors.New("error: missing user or secret")
+	}
+
+	return f.FetchFeed(f.PrivateURL(tags...))
+}
+
+// UnreadPosts returns the user's bookmarks marked to read later.
+func (f *Feeds) UnreadPosts() ([]*Post, error) {
+	if f.User == "" || f.Secret == "" {
+		return nil, errors.New("error: missing user or secret")
+	}
+
+	return f.FetchFeed(f.UnreadURL())
+}
+
+// NetworkPosts returns the bookmarks saved by the users in the user's
+// network.
+func (f *Feeds) NetworkPosts() ([]*Post, error) {
+	if f.User == "" || f.Secret == "" {
+		return nil, errors.New("error: missing user or secret")
+	}
+
+	return f.FetchFeed(f.NetworkURL())
+}
+
+// TagPosts returns everyone's public bookmarks with all of the given
+// tags.
+func (f *Feeds) TagPosts(tags ...string) ([]*Post, error) {
+	if len(tags) == 0 {
+		return nil, errors.New("error: missing tag")
+	}
+
+	return f.FetchFeed(f.TagURL(tags...))
+}
+
+// PopularPosts returns the popular bookmarks.
+func (f *Feeds) PopularPosts() ([]*Post, error) {
+	return f.FetchFeed(f.PopularURL())
+}
+
+// RecentPosts returns everyone's most recent public bookmarks.
+func (f *Feeds) RecentPosts() ([]*Post, error) {
+	return f.FetchFeed(f.RecentURL())
+}
+
+// FetchFeed downloads and parses the feed at feedURL. Shared, Toread, Meta
+// and Hash aren't in feeds and are left empty; Author is set to the
+// user who saved each bookmark.
+func (f *Feeds) FetchFeed(feedURL string) ([]*Post, error) {
+	var fetcher Fetcher
+	if f.Fetcher != nil {
+		fetcher = *f.Fetcher
+	}
+
+	pg, err := fetcher.get(context.Background(), feedURL)
+	if err != nil {
+		return nil, err
+	}
+
+	if pg.status != http.StatusOK {
+		return nil, &StatusError{pg.status}
+	}
+
+	return ParseFeed(pg.body)
+}
+
+// ParseFeed parses a Pinboard feed in either RSS or JSON format.
+func ParseFeed(body []byte) ([]*Post, error) {
+	trimmed := bytes.TrimSpace(body)
+	if len(trimmed) > 0 && trimmed[0] == '[' {
+		return parseJSONFeed(trimmed)
+	}
+
+	return parseRSSFeed(body)
+}
+
+// feedJSONItem is a bookmark in a JSON feed.
+type feedJSONItem struct {
+	URL         string   `json:"u"`
+	Description string   `json:"d"`
+	Extended    string   `json:"n"`
+	Time        string   `json:"dt"`
+	Author      string   `json:"a"`
+	Tags        []string `json:"t"`
+}
+
+// parseJSONFeed parses a JSON feed.
+func parseJSONFeed(body []byte) ([]*Post, error) {
+	var items []feedJSONItem
+	err := json.Unmarshal(body, &items)
+	if err != nil {
+		return nil, err
+	}
+
+	var posts []*Post
+	for _, item := range items {
+		p, err := newFeedPost(item.URL, item.Description, item.Extended, item.Time, item.Author, item.Tags)
+		if err != nil {
+			return nil, err
+		}
+
+		posts = append(posts, p)
+	}
+
+	return posts, nil
+}
+
+// feedRSSItem is a bookmark in an RSS feed. Pinboard serves RSS 1.0,
+// where the Dublin Core elements hold the date, author and tags.
+type feedRSSItem struct {
+	Title       string `xml:"title"`
+	Link        string `xml:"link"`
+	Description string `xml:"description"`
+	Date        string `xml:"http://purl.org/dc/elements/1.1/ date"`
+	Creator     string `xml:"http://purl.org/dc/elements/1.1/ creator"`
+	Subject     string `xml:"http://purl.org/dc/elements/1.1/ subject"`
+}
+
+// feedRSS is an RSS 1.0 or 2.0 document. RSS 1.0 puts items next to
+// the channel and RSS 2.0 inside it.
+type feedRSS struct {
+	Items   []feedRSSItem `xml:"item"`
+	Channel struct {
+		Items []feedRSSItem `xml:"item"`
+	} `xml:"channel"`
+}
+
+// parseRSSFeed parses an RSS feed.
+func parseRSSFeed(body []byte) ([]*Post, error) {
+	var doc feedRSS
+	err := xml.Unmarshal(body, &doc)
+	if err != nil {
+		return nil, err
+	}
+
+	var posts []*Post
+	for _, item := range append(doc.Items, doc.Channel.Items...) {
+		p, err := newFeedPost(item.Link, item.Title, item.Description, item.Date, item.Creator, strings.Fields(item.Subject))
+		if err != nil {
+			return nil, err
+		}
+
+		posts = append(posts, p)
+	}
+
+	return posts, nil
+}
+
+// newFeedPost returns a Post from the fields of a feed item.
+func newFeedPost(href, description, extended, dt, author string, tags []string) (*Post, error) {
+	u, err := url.Parse(strings.TrimSpace(href))
+	if err != nil {
+		return nil, err
+	}
+
+	t, err := time.Parse(time.RFC3339, strings.TrimSpace(dt))
+	if err != nil {
+		return nil, err
+	}
+
+	var cleaned []string
+	for _, tag := range tags {
+		if tag = strings.TrimSpace(tag); tag != "" {
+			cleaned = append(cleaned, tag)
+		}
+	}
+
+	return &Post{
+		Href:        u,
+		Description: description,
+		Extended:    []byte(extended),
+		Tags:        cleaned,
+		Time:        t,
+		Author:      author,
+	}, nil
+}
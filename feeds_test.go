@@ -0,0 +1,124 @@
+package pinboard
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+const testRSSFeed = `<?xml version="1.0" encoding="UTF-8"?>
+<rdf:RDF xmlns="http://purl.org/rss/1.0/" xmlns:rdf="http://www.w3.org/1999/02/22-rdf-syntax-ns#" xmlns:dc="http://purl.org/dc/elements/1.1/">
+  <channel rdf:about="https://pinboard.in">
+    <title>Pinboard (wally)</title>
+    <link>https://pinboard.in/u:wally/</link>
+  </channel>
+  <item rdf:about="https://golang.org/">
+    <title>The Go Programming Language</title>
+    <dc:date>2010-12-11T19:48:02+00:00</dc:date>
+    <link>https://golang.org/</link>
+    <dc:creator>wally</dc:creator>
+    <description>Go &amp; friends</description>
+    <dc:subject>go lang</dc:subject>
+  </item>
+  <item rdf:about="https://example.com/">
+    <title>Example</title>
+    <dc:date>2010-12-10T08:00:00+00:00</dc:date>
+    <link>https://example.com/</link>
+    <dc:creator>friend</dc:creator>
+  </item>
+</rdf:RDF>`
+
+const testJSONFeed = `[
+  {"u": "https://golang.org/", "d": "The Go Programming Language", "n": "Go & friends", "dt": "2010-12-11T19:48:02Z", "a": "wally", "t": ["go", "lang"]},
+  {"u": "https://example.com/", "d": "Example", "n": "", "dt": "2010-12-10T08:00:00Z", "a": "friend", "t": [""]}
+]`
+
+func TestFeedsURL(t *testing.T) {
+	f := &Feeds{User: "wally", Secret: "abc123"}
+
+	for got, expected := range map[string]string{
+		f.UserURL():            "https://feeds.pinboard.in/rss/u:wally/",
+		f.UserURL("go", "c++"): "https://feeds.pinboard.in/rss/u:wally/t:go/t:c++/",
+		f.PrivateURL("go"):     "https://feeds.pinboard.in/rss/secret:abc123/u:wally/t:go/",
+		f.UnreadURL():          "https://feeds.pinboard.in/rss/secret:abc123/u:wally/toread/",
+		f.NetworkURL():         "https://feeds.pinboard.in/rss/secret:abc123/u:wally/network/",
+		f.TagURL("go", "a/b"):  "https://feeds.pinboard.in/rss/t:go/t:a%2Fb/",
+		f.PopularURL():         "https://feeds.pinboard.in/rss/popular/",
+		(&Feeds{JSON: true, Count: 10}).RecentURL(): "https://feeds.pinboard.in/json/recent/?count=10",
+	} {
+		if got != expected {
+			t.Errorf("error: got %v, expected %v", got, expected)
+		}
+	}
+}
+
+func TestFeedsFetch(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.UserAgent() != "feeds-test" {
+			http.Error(w, "unexpected user agent", http.StatusForbidden)
+			return
+		}
+
+		switch r.URL.Path {
+		case "/rss/secret:abc123/u:wally/network/":
+			w.Write([]byte(testRSSFeed))
+		case "/json/secret:abc123/u:wally/network/":
+			w.Write([]byte(testJSONFeed))
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer srv.Close()
+
+	for _, json := range []bool{false, true} {
+		f := &Feeds{
+			Fetcher: &Fetcher{UserAgent: "feeds-test"},
+			BaseURL: srv.URL,
+			User:    "wally",
+			Secret:  "abc123",
+			JSON:    json,
+		}
+
+		posts, err := f.NetworkPosts()
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if len(posts) != 2 {
+			t.Fatalf("error: got %v posts, expected 2", len(posts))
+		}
+
+		p := posts[0]
+		if p.Href.String() != "https://golang.org/" || p.Description != "The Go Programming Language" {
+			t.Errorf("error: got %v %q", p.Href, p.Description)
+		}
+
+		if string(p.Extended) != "Go & friends" || p.Author != "wally" {
+			t.Errorf("error: got extended %q by %q", p.Extended, p.Author)
+		}
+
+		if len(p.Tags) != 2 || p.Tags[1] != "lang" || len(posts[1].Tags) != 0 {
+			t.Errorf("error: got tags %v and %v", p.Tags, posts[1].Tags)
+		}
+
+		if p.Time.Unix() != 1292096882 {
+			t.Errorf("error: got time %v", p.Time)
+		}
+
+		// Feed posts work with the helpers that take posts.
+		if groups := GroupByDomain(posts); len(groups) != 2 {
+			t.Errorf("error: got %v domain groups, expected 2", len(groups))
+		}
+	}
+
+	f := &Feeds{Fetcher: &Fetcher{UserAgent: "feeds-test"}, BaseURL: srv.URL, User: "nobody"}
+	_, err := f.UserPosts()
+	if se, ok := err.(*StatusError); !ok || se.StatusCode != http.StatusNotFound {
+		t.Errorf("error: got %v, expected http 404", err)
+	}
+
+	_, err = (&Feeds{BaseURL: srv.URL, User: "wally"}).PrivatePosts()
+	if err == nil {
+		t.Error("error: expected missing secret error")
+	}
+}
@@ -0,0 +1,306 @@
+package pinboard
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+const (
+	// DefaultFetchTimeout is how long a Fetcher waits for a page by
+	// default.
+	DefaultFetchTimeout = 10 * time.Second
+
+	// DefaultFetchMaxBytes is how much of a page a Fetcher reads
+	// by default.
+	DefaultFetchMaxBytes = 1 << 20
+)
+
+// Fetcher downloads web pages for the tools in this package that need
+// to look at the bookmarked pages themselves.
+type Fetcher struct {
+	// Client used for requests. Defaults to http.DefaultClient.
+	Client *http.Client
+
+	// Maximum time for each request, including reading the body.
+	// Defaults to DefaultFetchTimeout.
+	Timeout time.Duration
+
+	// Maximum number of bytes read from a page. Anything after
+	// that is ignored. Defaults to DefaultFetchMaxBytes.
+	MaxBytes int64
+
+	// User-Agent header sent with requests. Optional.
+	UserAgent string
+}
+
+// page is a downloaded web page.
+type page struct {
+	// URL the page was fetched from, after redirects.
+	url *url.URL
+
+	status      int
+	contentType string
+	location    string
+	body        []byte
+
+	// The body was longer than MaxBytes and was cut short.
+	truncated bool
+
+	// Raw request and response details, for archiving.
+	req        *http.Request
+	proto      string
+	statusText string
+	header     http.Header
+}
+
+// get downloads rawurl with the Fetcher's client, which follows
+// redirects unless told otherwise, and returns at most MaxBytes of its
+// body.
+func (f *Fetcher) get(ctx context.Context, rawurl string) (*page, error) {
+	client := f.Client
+	if client == nil {
+		client = http.DefaultClient
+	}
+
+	timeout := f.Timeout
+	if timeout == 0 {
+		timeout = DefaultFetchTimeout
+	}
+
+	max := f.MaxBytes
+	if max == 0 {
+		max = DefaultFetchMaxBytes
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, rawurl, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	if f.UserAgent != "" {
+		req.Header.Set("User-Agent", f.UserAgent)
+	}
+
+	res, err := client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer res.Body.Close()
+
+	// Read one byte more than max to tell whether the body was cut
+	// short.
+	body, err := ioutil.ReadAll(io.LimitReader(res.Body, max+1))
+	if err != nil {
+		return nil, err
+	}
+
+	truncated := int64(len(body)) > max
+	if truncated {
+		body = body[:max]
+	}
+
+	return &page{
+		url:         res.Request.URL,
+		status:      res.StatusCode,
+		contentType: res.Header.Get("Content-Type"),
+		location:    res.Header.Get("Location"),
+		body:        body,
+		truncated:   truncated,
+		req:         res.Request,
+		proto:       res.Proto,
+		statusText:  res.Status,
+		header:      res.Header,
+	}, nil
+}
+
+// PageMetadata holds the metadata found in a web page.
+type PageMetadata struct {
+	// URL the page was fetched from, after redirects.
+	URL string
+
+	// Text of the <title> element.
+	Title string
+
+	// Content of <meta name="description">.
+	Description string
+
+	// OpenGraph og:title and og:description.
+	OGTitle       string
+	OGDescription string
+
+	// Twitter card twitter:title and twitter:description.
+	TwitterTitle       string
+	TwitterDescription string
+
+	// Absolute URL of <link rel="canonical">.
+	Canonical string
+
+	// Content of <meta name="keywords">.
+	Keywords []string
+}
+
+// Fetch downloads rawurl and returns its metadata.
+func (f *Fetcher) Fetch(rawurl string) (*PageMetadata, error) {
+	p, err := f.get(context.Background(), rawurl)
+	if err != nil {
+		return nil, err
+	}
+
+	if p.status != http.StatusOK {
+		return nil, fmt.Errorf("error: %s: http %d", rawurl, p.status)
+	}
+
+	return ParseMetadata(p.url, p.body, p.contentType), nil
+}
+
+// ParseMetadata extracts the metadata from an HTML document fetched
+// from base. The charset is taken from contentType, the value of the
+// Content-Type header, or the document itself.
+func ParseMetadata(base *url.URL, body []byte, contentType string) *PageMetadata {
+	m := &PageMetadata{URL: base.String()}
+
+	z := newHTMLTokenizer(decodeHTML(body, contentType))
+	var inTitle bool
+	for {
+		t, ok := z.next()
+		if !ok {
+			break
+		}
+
+		switch t.typ {
+		case textToken:
+			if inTitle && m.Title == "" {
+				m.Title = collapseSpace(t.data)
+			}
+
+		case endTagToken:
+			if t.data == "title" {
+				inTitle = false
+			}
+			// Metadata belongs in the head.
+			if t.data == "head" {
+				return m
+			}
+
+		case startTagToken, selfClosingTagToken:
+			switch t.data {
+			case "title":
+				inTitle = t.typ == startTagToken
+			case "body":
+				return m
+			case "meta":
+				m.meta(&t)
+			case "link":
+				if !hasWordFold(t.attr("rel"), "canonical") || m.Canonical != "" {
+					continue
+				}
+				if u, err := base.Parse(strings.TrimSpace(t.attr("href"))); err == nil {
+					m.Canonical = u.String()
+				}
+			}
+		}
+	}
+
+	return m
+}
+
+// meta records the content of a <meta> tag.
+func (m *PageMetadata) meta(t *htmlToken) {
+	name := strings.ToLower(t.attr("name"))
+	if name == "" {
+		name = strings.ToLower(t.attr("property"))
+	}
+	content := collapseSpace(t.attr("content"))
+
+	var field *string
+	switch name {
+	case "description":
+		field = &m.Description
+	case "og:title":
+		field = &m.OGTitle
+	case "og:description":
+		field = &m.OGDescription
+	case "twitter:title":
+		field = &m.TwitterTitle
+	case "twitter:description":
+		field = &m.TwitterDescription
+	case "keywords":
+		for _, k := range strings.Split(content, ",") {
+			if k = strings.TrimSpace(k); k != "" {
+				m.Keywords = append(m.Keywords, k)
+			}
+		}
+		return
+	default:
+		return
+	}
+
+	if *field == "" {
+		*field = content
+	}
+}
+
+// hasWordFold reports whether the space separated list s contains
+// word, ignoring case.
+func hasWordFold(s, word string) bool {
+	for _, w := range strings.Fields(s) {
+		if strings.EqualFold(w, word) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// BestTitle returns the OpenGraph title, the Twitter card title or
+// the <title>, whichever is found first.
+func (m *PageMetadata) BestTitle() string {
+	return firstNonEmpty(m.OGTitle, m.TwitterTitle, m.Title)
+}
+
+// BestDescription returns the OpenGraph description, the Twitter card
+// description or the meta description, whichever is found first.
+func (m *PageMetadata) BestDescription() string {
+	return firstNonEmpty(m.OGDescription, m.TwitterDescription, m.Description)
+}
+
+// firstNonEmpty returns the first non-empty string.
+func firstNonEmpty(s ...string) string {
+	for _, v := range s {
+		if v != "" {
+			return v
+		}
+	}
+
+	return ""
+}
+
+// PostsAddOptions returns options for adding the page as a bookmark.
+// The URL is the canonical URL if the page declares one. Keywords are
+// turned into tags, with spaces replaced by dashes since Pinboard tags
+// can't contain spaces.
+func (m *PageMetadata) PostsAddOptions() *PostsAddOptions {
+	opt := &PostsAddOptions{
+		URL:         firstNonEmpty(m.Canonical, m.URL),
+		Description: m.BestTitle(),
+		Extended:    []byte(m.BestDescription()),
+	}
+
+	for _, k := range m.Keywords {
+		if len(opt.Tags) == 100 {
+			break
+		}
+		opt.Tags = append(opt.Tags, strings.Join(strings.Fields(k), "-"))
+	}
+
+	return opt
+}
@@ -0,0 +1,80 @@
+package pinboard
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestFetcherFetch(t *testing.T) {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/article", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/html; charset=iso-8859-1")
+		fmt.Fprint(w, "<html><head>\n"+
+			"<title>Caf\xe9 &amp; Co</title>\n"+
+			"<meta property=\"og:title\" content=\"The Caf\xe9\">\n"+
+			"<meta name=twitter:description content='A small   place'>\n"+
+			"<meta name=\"description\" content=\"Meta description\">\n"+
+			"<meta name=\"keywords\" content=\"coffee, small business,\">\n"+
+			"<link rel=\"Canonical\" href=\"/canonical\">\n"+
+			"</head><body><meta name=\"description\" content=\"ignored\"></body></html>")
+	})
+	mux.HandleFunc("/broken", func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, "<title>Unclosed <b>title")
+	})
+	mux.HandleFunc("/slow", func(w http.ResponseWriter, r *http.Request) {
+		time.Sleep(100 * time.Millisecond)
+	})
+	mux.HandleFunc("/big", func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, strings.Repeat(" ", 100)+"<title>Too far</title>")
+	})
+
+	srv := httptest.NewServer(mux)
+	defer srv.Close()
+
+	f := &Fetcher{}
+
+	m, err := f.Fetch(srv.URL + "/article")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if m.Title != "Café & Co" || m.OGTitle != "The Café" || m.Description != "Meta description" {
+		t.Errorf("error: unexpected metadata %+v", m)
+	}
+
+	opt := m.PostsAddOptions()
+	if opt.URL != srv.URL+"/canonical" || opt.Description != "The Café" || string(opt.Extended) != "A small place" {
+		t.Errorf("error: unexpected options %+v", opt)
+	}
+
+	if len(opt.Tags) != 2 || opt.Tags[1] != "small-business" {
+		t.Errorf("error: got tags %v, expected [coffee small-business]", opt.Tags)
+	}
+
+	m, err = f.Fetch(srv.URL + "/broken")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if m.Title != "Unclosed <b>title" {
+		t.Errorf("error: got title %q", m.Title)
+	}
+
+	_, err = (&Fetcher{Timeout: 10 * time.Millisecond}).Fetch(srv.URL + "/slow")
+	if err == nil {
+		t.Error("error: expected timeout error")
+	}
+
+	m, err = (&Fetcher{MaxBytes: 50}).Fetch(srv.URL + "/big")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if m.Title != "" {
+		t.Errorf("error: expected title past MaxBytes to be ignored, got %q", m.Title)
+	}
+}
@@ -0,0 +1,39 @@
+package pinboard
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestWriteFileAtomic(t *testing.T) {
+	dir, err := ioutil.TempDir("", "atomic")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	path := filepath.Join(dir, "state.json")
+	for _, data := range []string{"first", "second"} {
+		err = writeFileAtomic(path, []byte(data), 0644)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	data, err := ioutil.ReadFile(path)
+	if err != nil || string(data) != "second" {
+		t.Errorf("error: got %q, %v, expected second", data, err)
+	}
+
+	fi, err := os.Stat(path)
+	if err != nil || fi.Mode().Perm() != 0644 {
+		t.Errorf("error: got mode %v, expected 0644", fi.Mode())
+	}
+
+	files, _ := ioutil.ReadDir(dir)
+	if len(files) != 1 {
+		t.Errorf("error: got %v files, expected temporary files to be removed", len(files))
+	}
+}
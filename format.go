@@ -0,0 +1,161 @@
+package pinboard
+
+import (
+	"bufio"
+	"fmt"
+	"html"
+	"io"
+	"strings"
+)
+
+// Format is an output format for digests.
+type Format string
+
+const (
+	FormatMarkdown Format = "markdown"
+	FormatHTML     Format = "html"
+	FormatText     Format = "text"
+)
+
+// section is a part of a digest holding bookmarks, nested sections or
+// both. A section without a title has no heading.
+type section struct {
+	title    string
+	posts    []*Post
+	sections []section
+}
+
+// writeSections writes a document with the given title and sections
+// in format f. HTML output is a standalone page.
+func writeSections(w io.Writer, f Format, title string, sections []section) error {
+	bw := bufio.NewWriter(w)
+
+	switch f {
+	case FormatMarkdown:
+		fmt.Fprintf(bw, "# %s\n", title)
+	case FormatHTML:
+		fmt.Fprintf(bw, "<!DOCTYPE html>\n<html>\n<head>\n<meta charset=\"utf-8\">\n<title>%s</title>\n</head>\n<body>\n", html.EscapeString(title))
+		fmt.Fprintf(bw, "<h1>%s</h1>\n", html.EscapeString(title))
+	case FormatText:
+		fmt.Fprintf(bw, "%s\n%s\n", title, strings.Repeat("=", len([]rune(title))))
+	default:
+		return fmt.Errorf("error: unknown format %q", f)
+	}
+
+	for _, s := range sections {
+		writeSection(bw, f, s, 2)
+	}
+
+	if f == FormatHTML {
+		bw.WriteString("</body>\n</html>\n")
+	}
+
+	return bw.Flush()
+}
+
+// writeSection writes s with a heading of the given level.
+func writeSection(w *bufio.Writer, f Format, s section, level int) {
+	switch {
+	case s.title == "":
+	case f == FormatMarkdown:
+		fmt.Fprintf(w, "\n%s %s\n", strings.Repeat("#", level), markdownEscaper.Replace(s.title))
+	case f == FormatHTML:
+		fmt.Fprintf(w, "<h%d>%s</h%d>\n", level, html.EscapeString(s.title), level)
+	case f == FormatText:
+		underline := "-"
+		if level > 2 {
+			underline = "."
+		}
+		fmt.Fprintf(w, "\n%s\n%s\n", s.title, strings.Repeat(underline, len([]rune(s.title))))
+	}
+
+	if len(s.posts) > 0 {
+		if f == FormatHTML {
+			w.WriteString("<ul>\n")
+		} else {
+			w.WriteString("\n")
+		}
+
+		for _, p := range s.posts {
+			writePost(w, f, p)
+		}
+
+		if f == FormatHTML {
+			w.WriteString("</ul>\n")
+		}
+	}
+
+	for _, sub := range s.sections {
+		writeSection(w, f, sub, level+1)
+	}
+}
+
+// writePost writes a single bookmark as a list item.
+func writePost(w *bufio.Writer, f Format, p *Post) {
+	title := firstNonEmpty(p.Description, p.Href.String())
+	extended := strings.TrimSpace(string(p.Extended))
+	date := p.Time.Format("2 January 2006")
+	tags := cleanTags(p.Tags)
+
+	switch f {
+	case FormatMarkdown:
+		fmt.Fprintf(w, "- [%s](%s)", markdownEscaper.Replace(title), markdownURL(p.Href.String()))
+		if len(tags) > 0 {
+			fmt.Fprintf(w, " `%s`", strings.Join(tags, "` `"))
+		}
+		fmt.Fprintf(w, " (%s)\n", date)
+		if extended != "" {
+			for _, line := range strings.Split(extended, "\n") {
+				fmt.Fprintf(w, "  > %s\n", line)
+			}
+		}
+
+	case FormatHTML:
+		fmt.Fprintf(w, "<li><a href=\"%s\">%s</a>", html.EscapeString(p.Href.String()), html.EscapeString(title))
+		if len(tags) > 0 {
+			fmt.Fprintf(w, " <small>%s</small>", html.EscapeString(strings.Join(tags, " ")))
+		}
+		fmt.Fprintf(w, " <small>%s</small>", date)
+		if extended != "" {
+			fmt.Fprintf(w, "<br>\n<blockquote>%s</blockquote>", strings.Replace(html.EscapeString(extended), "\n", "<br>\n", -1))
+		}
+		w.WriteString("</li>\n")
+
+	case FormatText:
+		fmt.Fprintf(w, "* %s\n  %s\n", title, p.Href)
+		if len(tags) > 0 {
+			fmt.Fprintf(w, "  Tags: %s\n", strings.Join(tags, " "))
+		}
+		fmt.Fprintf(w, "  Saved %s\n", date)
+		if extended != "" {
+			for _, line := range strings.Split(extended, "\n") {
+				fmt.Fprintf(w, "  | %s\n", line)
+			}
+		}
+	}
+}
+
+// markdownURL returns rawurl as a Markdown link destination in angle
+// brackets, so that parentheses and spaces in it don't end the link.
+func markdownURL(rawurl string) string {
+	return "<" + markdownURLEscaper.Replace(rawurl) + ">"
+}
+
+// markdownURLEscaper percent-encodes the characters that can't appear
+// in a link destination in angle brackets.
+var markdownURLEscaper = strings.NewReplacer(
+	"<", "%3C",
+	">", "%3E",
+	"\n", "%0A",
+)
+
+// markdownEscaper escapes the characters that would end a Markdown
+// link's text early or start inline markup.
+var markdownEscaper = strings.NewReplacer(
+	`\`, `\\`,
+	`[`, `\[`,
+	`]`, `\]`,
+	"`", "\\`",
+	`*`, `\*`,
+	`_`, `\_`,
+)
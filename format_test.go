@@ -0,0 +1,59 @@
+package pinboard
+
+import (
+	"bufio"
+	"bytes"
+	"strings"
+	"testing"
+)
+
+func TestWritePostUntagged(t *testing.T) {
+	p := apiPost("https://golang.org/", "Go", "")
+
+	for f, unexpected := range map[Format]string{
+		FormatMarkdown: "``",
+		FormatHTML:     "<small></small>",
+		FormatText:     "Tags:",
+	} {
+		var buf bytes.Buffer
+		w := bufio.NewWriter(&buf)
+		writePost(w, f, p)
+		w.Flush()
+
+		if strings.Contains(buf.String(), unexpected) {
+			t.Errorf("error: expected no tags in %s output:\n%s", f, buf.String())
+		}
+	}
+}
+
+func TestWriteSectionMarkdown(t *testing.T) {
+	p := testPost("https://en.wikipedia.org/wiki/Go_(game)", "Go *the game*", "games")
+
+	var buf bytes.Buffer
+	w := bufio.NewWriter(&buf)
+	writeSection(w, FormatMarkdown, section{title: "Tagged [games]", posts: []*Post{p}}, 2)
+	w.Flush()
+
+	expected := "\n## Tagged \\[games\\]\n\n" +
+		"- [Go \\*the game\\*](<https://en.wikipedia.org/wiki/Go_(game)>) `games` (11 December 2010)\n"
+	if buf.String() != expected {
+		t.Errorf("error: got %q, expected %q", buf.String(), expected)
+	}
+}
+
+func TestMarkdownURL(t *testing.T) {
+	tests := []struct {
+		url      string
+		expected string
+	}{
+		{"https://example.com/a b", "<https://example.com/a b>"},
+		{"https://example.com/?q=<b>", "<https://example.com/?q=%3Cb%3E>"},
+	}
+
+	for _, test := range tests {
+		got := markdownURL(test.url)
+		if got != test.expected {
+			t.Errorf("error: got %v, expected %v", got, test.expected)
+		}
+	}
+}
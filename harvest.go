@@ -0,0 +1,347 @@
+package pinboard
+
+import (
+	"io/ioutil"
+	"net/url"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"time"
+	"unicode"
+)
+
+// Link is a link found in a document.
+type Link struct {
+	URL string
+
+	// Text of the link, or the text around it for a bare URL.
+	Text string
+
+	// Where the link was found, such as a file name or note title.
+	Source string
+}
+
+var (
+	// mdInlineLink matches [text](url "title"), and images, which
+	// start with "!".
+	mdInlineLink = regexp.MustCompile(`(!?)\[([^\]]*)\]\(\s*<?([^\s()<>]+)>?(?:\s+(?:"[^"]*"|'[^']*'))?\s*\)`)
+
+	// mdRefLink matches [text][ref] and [text][].
+	mdRefLink = regexp.MustCompile(`(!?)\[([^\]]+)\]\[([^\]]*)\]`)
+
+	// mdRefDef matches a reference definition: [ref]: url "title".
+	mdRefDef = regexp.MustCompile(`(?m)^ {0,3}\[([^\]]+)\]:[ \t]*<?(\S+?)>?(?:[ \t]+.*)?$`)
+
+	// mdAutolink matches <url>, which Markdown shows as the URL
+	// itself.
+	mdAutolink = regexp.MustCompile(`<([a-zA-Z][a-zA-Z0-9+.-]{1,31}:[^\s<>]+)>`)
+
+	// bareURL matches URLs in plain text.
+	bareURL = regexp.MustCompile("https?://[^\\s<>\"'`]+")
+)
+
+// blank replaces the text matched by re with spaces, so that later
+// passes don't find the same links again.
+func blank(s string, re *regexp.Regexp) string {
+	return re.ReplaceAllStringFunc(s, func(m string) string {
+		return strings.Repeat(" ", len(m))
+	})
+}
+
+// ExtractMarkdownLinks returns the inline, reference, autolinked and
+// bare links in a Markdown document, leaving out images.
+func ExtractMarkdownLinks(text string) []Link {
+	var links []Link
+
+	refs := make(map[string]string)
+	for _, m := range mdRefDef.FindAllStringSubmatch(text, -1) {
+		refs[strings.ToLower(m[1])] = m[2]
+	}
+	text = blank(text, mdRefDef)
+
+	for _, m := range mdInlineLink.FindAllStringSubmatch(text, -1) {
+		if m[1] == "" {
+			links = append(links, Link{URL: m[3], Text: collapseSpace(m[2])})
+		}
+	}
+	text = blank(text, mdInlineLink)
+
+	for _, m := range mdRefLink.FindAllStringSubmatch(text, -1) {
+		ref := m[3]
+		if ref == "" {
+			ref = m[2]
+		}
+
+		if u, ok := refs[strings.ToLower(ref)]; ok && m[1] == "" {
+			links = append(links, Link{URL: u, Text: collapseSpace(m[2])})
+		}
+	}
+	text = blank(text, mdRefLink)
+
+	for _, m := range mdAutolink.FindAllStringSubmatch(text, -1) {
+		links = append(links, Link{URL: m[1], Text: m[1]})
+	}
+	text = blank(text, mdAutolink)
+
+	return append(links, ExtractTextLinks(text)...)
+}
+
+// ExtractHTMLLinks returns the links in an HTML document fetched from
+// base, with relative links resolved against it. The charset is taken
+// from contentType or the document itself.
+func ExtractHTMLLinks(base *url.URL, body []byte, contentType string) []Link {
+	var links []Link
+	var current *Link
+	var text strings.Builder
+
+	z := newHTMLTokenizer(decodeHTML(body, contentType))
+	for {
+		t, ok := z.next()
+		if !ok {
+			break
+		}
+
+		switch t.typ {
+		case startTagToken:
+			if t.data != "a" {
+				continue
+			}
+
+			href := strings.TrimSpace(t.attr("href"))
+			u, err := base.Parse(href)
+			if err != nil || href == "" {
+				current = nil
+				continue
+			}
+
+			u.Fragment = ""
+			current = &Link{URL: u.String(), Text: t.attr("title")}
+			text.Reset()
+
+		case textToken:
+			if current != nil {
+				text.WriteString(t.data)
+			}
+
+		case endTagToken:
+			if t.data == "a" && current != nil {
+				current.Text = firstNonEmpty(collapseSpace(text.String()), current.Text)
+				links = append(links, *current)
+				current = nil
+			}
+		}
+	}
+
+	return links
+}
+
+// ExtractTextLinks returns the URLs in plain text. The text of each
+// link is the rest of the line it is on.
+func ExtractTextLinks(text string) []Link {
+	var links []Link
+
+	for _, line := range strings.Split(text, "\n") {
+		for _, loc := range bareURL.FindAllStringIndex(line, -1) {
+			u := trimURL(line[loc[0]:loc[1]])
+			rest := line[:loc[0]] + line[loc[0]+len(u):]
+
+			links = append(links, Link{
+				URL:  u,
+				Text: strings.TrimFunc(collapseSpace(bareURL.ReplaceAllString(rest, "")), notWord),
+			})
+		}
+	}
+
+	return links
+}
+
+// notWord reports whether r is punctuation or space, which is
+// trimmed from around the text of a bare URL.
+func notWord(r rune) bool {
+	return unicode.IsSpace(r) || unicode.IsPunct(r) || unicode.IsSymbol(r)
+}
+
+// trimURL removes the punctuation that usually follows a URL in a
+// sentence, along with closing parentheses that have no opening one
+// in the URL.
+func trimURL(u string) string {
+	for len(u) > 0 {
+		last := u[len(u)-1]
+		switch {
+		case strings.IndexByte(".,;:!?'\"", last) >= 0:
+			u = u[:len(u)-1]
+		case last == ')' && strings.Count(u, "(") < strings.Count(u, ")"):
+			u = u[:len(u)-1]
+		default:
+			return u
+		}
+	}
+
+	return u
+}
+
+// Harvester turns links found in documents and notes into proposed
+// bookmarks, leaving out those already bookmarked.
+type Harvester struct {
+	// Bookmarks already in the account. If nil, they are fetched
+	// with PostsAll the first time they are needed.
+	Existing []*Post
+
+	// Canonicalizer used to compare URLs. Defaults to
+	// DefaultCanonicalizer.
+	Canonicalizer *Canonicalizer
+
+	// Tag added to every proposal to tell where it came from.
+	// Defaults to "harvested".
+	Tag string
+
+	// Time between API calls when reading notes. Defaults to
+	// RateLimit.
+	Interval time.Duration
+
+	api harvestAPI
+}
+
+// harvestAPI holds the API calls made by a Harvester, so that tests can
+// replace them.
+type harvestAPI struct {
+	postsAll  func(opt *PostsAllOptions) ([]*Post, error)
+	notesList func() ([]*Note, error)
+	notesID   func(id string) (*Note, error)
+}
+
+var defaultHarvestAPI = harvestAPI{
+	postsAll:  PostsAll,
+	notesList: NotesList,
+	notesID:   NotesID,
+}
+
+// client returns the API calls to make, which are the real ones
+// unless a test replaced them.
+func (h *Harvester) client() harvestAPI {
+	if h.api.postsAll == nil {
+		return defaultHarvestAPI
+	}
+
+	return h.api
+}
+
+// Propose returns options for bookmarking each link that isn't
+// already bookmarked or proposed, in order. The link text is the
+// title and the source is noted in the description. Only http and
+// https links are proposed.
+func (h *Harvester) Propose(links []Link) ([]*PostsAddOptions, error) {
+	if h.Existing == nil {
+		existing, err := h.client().postsAll(nil)
+		if err != nil {
+			return nil, err
+		}
+		h.Existing = existing
+	}
+
+	c := h.Canonicalizer
+	if c == nil {
+		c = DefaultCanonicalizer
+	}
+
+	key := func(rawurl string) string {
+		k, err := c.Canonicalize(rawurl)
+		if err != nil {
+			return rawurl
+		}
+		return k
+	}
+
+	seen := make(map[string]bool)
+	for _, p := range h.Existing {
+		seen[key(p.Href.String())] = true
+	}
+
+	var proposals []*PostsAddOptions
+	for _, l := range links {
+		u, err := url.Parse(l.URL)
+		if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			continue
+		}
+
+		k := key(l.URL)
+		if seen[k] {
+			continue
+		}
+		seen[k] = true
+
+		opt := &PostsAddOptions{
+			URL:         l.URL,
+			Description: firstNonEmpty(l.Text, l.URL),
+			Tags:        []string{firstNonEmpty(h.Tag, "harvested")},
+		}
+		if l.Source != "" {
+			opt.Extended = []byte("Found in " + l.Source + ".")
+		}
+
+		proposals = append(proposals, opt)
+	}
+
+	return proposals, nil
+}
+
+// HarvestFile proposes bookmarks for the links in a file, read as
+// Markdown, HTML or plain text depending on its extension.
+func (h *Harvester) HarvestFile(path string) ([]*PostsAddOptions, error) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	var links []Link
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".md", ".markdown", ".mdown":
+		links = ExtractMarkdownLinks(string(data))
+	case ".html", ".htm", ".xhtml":
+		abs, _ := filepath.Abs(path)
+		links = ExtractHTMLLinks(&url.URL{Scheme: "file", Path: filepath.ToSlash(abs)}, data, "")
+	default:
+		links = ExtractTextLinks(string(data))
+	}
+
+	for i := range links {
+		links[i].Source = filepath.Base(path)
+	}
+
+	return h.Propose(links)
+}
+
+// HarvestNotes proposes bookmarks for the links in the user's notes.
+// NotesList doesn't return the text of notes, so each one is fetched
+// with NotesID. Notes are read as Markdown, which Pinboard renders
+// them with.
+func (h *Harvester) HarvestNotes() ([]*PostsAddOptions, error) {
+	api := h.client()
+
+	notes, err := api.notesList()
+	if err != nil {
+		return nil, err
+	}
+
+	interval := h.Interval
+	if interval == 0 {
+		interval = RateLimit
+	}
+	pc := &pacer{interval: interval}
+
+	var links []Link
+	for _, n := range notes {
+		pc.wait()
+		note, err := api.notesID(n.ID)
+		if err != nil {
+			return nil, err
+		}
+
+		for _, l := range ExtractMarkdownLinks(string(note.Text)) {
+			l.Source = "note " + firstNonEmpty(note.Title, n.Title, n.ID)
+			links = append(links, l)
+		}
+	}
+
+	return h.Propose(links)
+}
@@ -0,0 +1,165 @@
+package pinboard
+
+import (
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestExtractMarkdownLinks(t *testing.T) {
+	links := ExtractMarkdownLinks(`# Reading
+
+See [the Go blog](https://blog.golang.org/ "Go Blog") and ![logo](https://golang.org/logo.png).
+Also [Pinboard][pb], [Example][] and <https://autolink.com/>.
+
+Bare: https://bare.com/path).
+
+[pb]: https://pinboard.in/
+[example]: https://example.com/ 'Example'
+`)
+
+	expected := []Link{
+		{URL: "https://blog.golang.org/", Text: "the Go blog"},
+		{URL: "https://pinboard.in/", Text: "Pinboard"},
+		{URL: "https://example.com/", Text: "Example"},
+		{URL: "https://autolink.com/", Text: "https://autolink.com/"},
+		{URL: "https://bare.com/path", Text: "Bare"},
+	}
+
+	if !reflect.DeepEqual(links, expected) {
+		t.Errorf("error: got %+v, expected %+v", links, expected)
+	}
+}
+
+func TestExtractHTMLLinks(t *testing.T) {
+	base, _ := url.Parse("https://example.com/dir/page.html")
+	links := ExtractHTMLLinks(base, []byte(`<p>Read <a href="../other.html#top">the
+		<b>other</b> page</a> and <a href="https://golang.org/" title="Go"><img src="go.png"></a>.
+		<a name="anchor">no href</a></p>`), "text/html")
+
+	if len(links) != 2 {
+		t.Fatalf("error: got %+v, expected 2 links", links)
+	}
+
+	if links[0].URL != "https://example.com/other.html" || links[0].Text != "the other page" {
+		t.Errorf("error: got %+v", links[0])
+	}
+
+	if links[1].URL != "https://golang.org/" || links[1].Text != "Go" {
+		t.Errorf("error: got %+v", links[1])
+	}
+}
+
+func TestExtractTextLinks(t *testing.T) {
+	links := ExtractTextLinks("Go - https://golang.org/.\n(see https://en.wikipedia.org/wiki/Go_(programming_language))")
+
+	if len(links) != 2 {
+		t.Fatalf("error: got %+v, expected 2 links", links)
+	}
+
+	if links[0].URL != "https://golang.org/" || links[0].Text != "Go" {
+		t.Errorf("error: got %+v", links[0])
+	}
+
+	if links[1].URL != "https://en.wikipedia.org/wiki/Go_(programming_language)" || links[1].Text != "see" {
+		t.Errorf("error: got %+v", links[1])
+	}
+}
+
+func TestHarvester(t *testing.T) {
+	dir, err := ioutil.TempDir("", "harvest")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	path := filepath.Join(dir, "links.md")
+	err = ioutil.WriteFile(path, []byte(`- [Go](https://golang.org/)
+- [Go again](http://www.golang.org)
+- [Example](https://example.com/?utm_source=mail)
+- [Mail](mailto:me@example.com)
+`), 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	h := &Harvester{
+		Existing: []*Post{testPost("https://example.com/", "Example")},
+		Tag:      "via:links",
+	}
+
+	proposals, err := h.HarvestFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(proposals) != 1 {
+		t.Fatalf("error: got %+v, expected only golang.org to be proposed", proposals)
+	}
+
+	p := proposals[0]
+	if p.URL != "https://golang.org/" || p.Description != "Go" || p.Tags[0] != "via:links" {
+		t.Errorf("error: got %+v", p)
+	}
+
+	if string(p.Extended) != "Found in links.md." {
+		t.Errorf("error: got extended %q", p.Extended)
+	}
+}
+
+func TestHarvesterAPI(t *testing.T) {
+	var fetched int
+	h := &Harvester{
+		Interval: time.Millisecond,
+		api: harvestAPI{
+			postsAll: func(opt *PostsAllOptions) ([]*Post, error) {
+				fetched++
+				return []*Post{testPost("https://example.com/", "Example")}, nil
+			},
+			notesList: func() ([]*Note, error) {
+				return []*Note{{ID: "abc", Title: "Links"}}, nil
+			},
+			notesID: func(id string) (*Note, error) {
+				if id != "abc" {
+					t.Errorf("error: got note id %v, expected abc", id)
+				}
+				return &Note{ID: id, Title: "Links", Text: []byte("See <https://golang.org/> and [Example](https://example.com/).")}, nil
+			},
+		},
+	}
+
+	for i := 0; i < 2; i++ {
+		proposals, err := h.HarvestNotes()
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if len(proposals) != 1 || proposals[0].URL != "https://golang.org/" || proposals[0].Description != "https://golang.org/" {
+			t.Fatalf("error: got %+v, expected only golang.org to be proposed", proposals)
+		}
+
+		if string(proposals[0].Extended) != "Found in note Links." {
+			t.Errorf("error: got extended %q", proposals[0].Extended)
+		}
+	}
+
+	if fetched != 1 {
+		t.Errorf("error: fetched existing bookmarks %v times, expected once", fetched)
+	}
+
+	h = &Harvester{api: harvestAPI{
+		postsAll: func(opt *PostsAllOptions) ([]*Post, error) {
+			return nil, &StatusError{http.StatusTooManyRequests}
+		},
+	}}
+
+	_, err := h.Propose([]Link{{URL: "https://golang.org/"}})
+	if se, ok := err.(*StatusError); !ok || se.StatusCode != http.StatusTooManyRequests {
+		t.Errorf("error: got %v, expected http 429", err)
+	}
+}
@@ -0,0 +1,42 @@
+package pinboard
+
+import (
+	"crypto/md5"
+	"fmt"
+	"net/url"
+	"time"
+)
+
+// testPost returns a Post for tests that don't need to hit the API. Its
+// Hash is hex like Pinboard's, but deliberately not the MD5 of href, so
+// tests can tell the two apart.
+func testPost(href, description string, tags ...string) *Post {
+	u, _ := url.Parse(href)
+	dt, _ := time.Parse(time.RFC3339, "2010-12-11T19:48:02Z")
+
+	return &Post{
+		Href:        u,
+		Description: description,
+		Tags:        tags,
+		Time:        dt,
+		Meta:        []byte("meta-" + href),
+		Hash:        []byte(fmt.Sprintf("%x", md5.Sum([]byte("hash-"+href)))),
+	}
+}
+
+// apiPost returns a Post converted from API data the way PostsGet and
+// PostsAll do, so that an untagged post has the tags [""].
+func apiPost(href, description, tags string) *Post {
+	p, err := (&post{
+		Href:        href,
+		Description: descriptionType(description),
+		Tags:        tags,
+		Time:        "2010-12-11T19:48:02Z",
+		Hash:        fmt.Sprintf("%x", md5.Sum([]byte("hash-"+href))),
+	}).toPost()
+	if err != nil {
+		panic(err)
+	}
+
+	return p
+}
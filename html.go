@@ -0,0 +1,377 @@
+package pinboard
+
+import (
+	"bytes"
+	"html"
+	"mime"
+	"strings"
+	"unicode/utf8"
+)
+
+// htmlTokenType is the kind of an htmlToken.
+type htmlTokenType int
+
+const (
+	textToken htmlTokenType = iota
+	startTagToken
+	endTagToken
+	selfClosingTagToken
+	commentToken
+)
+
+// htmlAttr is an attribute of a tag. Keys are lower case and values
+// have their entities decoded.
+type htmlAttr struct {
+	key, val string
+}
+
+// htmlToken is a piece of an HTML document. For tags data is the lower
+// case tag name, for text and comments it is the content with
+// entities decoded.
+type htmlToken struct {
+	typ   htmlTokenType
+	data  string
+	attrs []htmlAttr
+}
+
+// attr returns the value of the attribute key, or "" if the tag
+// doesn't have it.
+func (t *htmlToken) attr(key string) string {
+	for _, a := range t.attrs {
+		if a.key == key {
+			return a.val
+		}
+	}
+
+	return ""
+}
+
+// rawTextElements hold text that isn't parsed as HTML, up to their
+// closing tag.
+var rawTextElements = map[string]bool{
+	"script":   true,
+	"style":    true,
+	"title":    true,
+	"textarea": true,
+}
+
+// htmlTokenizer splits an HTML document into tokens. It is a small,
+// forgiving tokenizer for pulling metadata, links and text out of
+// pages: anything it doesn't understand is treated as text.
+type htmlTokenizer struct {
+	src string
+	pos int
+
+	// Name of the raw text element whose content comes next.
+	raw string
+}
+
+// newHTMLTokenizer returns a tokenizer for src, which must be UTF-8.
+func newHTMLTokenizer(src string) *htmlTokenizer {
+	return &htmlTokenizer{src: src}
+}
+
+// next returns the next token, or false at the end of the document.
+func (z *htmlTokenizer) next() (htmlToken, bool) {
+	if z.pos >= len(z.src) {
+		return htmlToken{}, false
+	}
+
+	if z.raw != "" {
+		return z.rawText(), true
+	}
+
+	rest := z.src[z.pos:]
+	if rest[0] != '<' || len(rest) < 2 {
+		return z.text(), true
+	}
+
+	switch c := rest[1]; {
+	case strings.HasPrefix(rest, "<!--"):
+		end := strings.Index(rest[4:], "-->")
+		if end < 0 {
+			z.pos = len(z.src)
+			return htmlToken{typ: commentToken, data: rest[4:]}, true
+		}
+		z.pos += 4 + end + 3
+		return htmlToken{typ: commentToken, data: rest[4 : 4+end]}, true
+
+	case c == '!' || c == '?':
+		// Doctype, CDATA or processing instruction.
+		end := strings.IndexByte(rest, '>')
+		if end < 0 {
+			end = len(rest) - 1
+		}
+		z.pos += end + 1
+		return htmlToken{typ: commentToken, data: rest[2:end]}, true
+
+	case c == '/':
+		if len(rest) > 2 && isASCIILetter(rest[2]) {
+			return z.tag(), true
+		}
+
+	case isASCIILetter(c):
+		return z.tag(), true
+	}
+
+	return z.text(), true
+}
+
+// text returns the text up to the next tag.
+func (z *htmlTokenizer) text() htmlToken {
+	start := z.pos
+	end := strings.IndexByte(z.src[start+1:], '<')
+	if end < 0 {
+		z.pos = len(z.src)
+	} else {
+		z.pos = start + 1 + end
+	}
+
+	return htmlToken{typ: textToken, data: html.UnescapeString(z.src[start:z.pos])}
+}
+
+// rawText returns the content of a raw text element.
+func (z *htmlTokenizer) rawText() htmlToken {
+	name := z.raw
+	z.raw = ""
+
+	rest := z.src[z.pos:]
+	end := indexFold(rest, "</"+name)
+	if end < 0 {
+		end = len(rest)
+	}
+	z.pos += end
+
+	data := rest[:end]
+	if name == "title" || name == "textarea" {
+		data = html.UnescapeString(data)
+	}
+
+	return htmlToken{typ: textToken, data: data}
+}
+
+// tag parses a start or end tag, including its attributes.
+func (z *htmlTokenizer) tag() htmlToken {
+	t := htmlToken{typ: startTagToken}
+
+	i := z.pos + 1
+	if z.src[i] == '/' {
+		t.typ = endTagToken
+		i++
+	}
+
+	start := i
+	for i < len(z.src) && !isHTMLSpace(z.src[i]) && z.src[i] != '>' && z.src[i] != '/' {
+		i++
+	}
+	t.data = strings.ToLower(z.src[start:i])
+
+	for i < len(z.src) {
+		for i < len(z.src) && isHTMLSpace(z.src[i]) {
+			i++
+		}
+		if i >= len(z.src) {
+			break
+		}
+
+		if z.src[i] == '>' {
+			i++
+			break
+		}
+
+		if z.src[i] == '/' {
+			if i+1 < len(z.src) && z.src[i+1] == '>' {
+				if t.typ == startTagToken {
+					t.typ = selfClosingTagToken
+				}
+				i += 2
+				break
+			}
+			i++
+			continue
+		}
+
+		// Attribute name.
+		start := i
+		for i < len(z.src) && !isHTMLSpace(z.src[i]) && z.src[i] != '=' && z.src[i] != '>' && z.src[i] != '/' {
+			i++
+		}
+		if i == start {
+			i++
+			continue
+		}
+		key := strings.ToLower(z.src[start:i])
+
+		for i < len(z.src) && isHTMLSpace(z.src[i]) {
+			i++
+		}
+
+		var val string
+		if i < len(z.src) && z.src[i] == '=' {
+			i++
+			for i < len(z.src) && isHTMLSpace(z.src[i]) {
+				i++
+			}
+
+			if i < len(z.src) && (z.src[i] == '"' || z.src[i] == '\'') {
+				quote := z.src[i]
+				end := strings.IndexByte(z.src[i+1:], quote)
+				if end < 0 {
+					end = len(z.src) - i - 1
+				}
+				val = z.src[i+1 : i+1+end]
+				i += end + 2
+			} else {
+				start := i
+				for i < len(z.src) && !isHTMLSpace(z.src[i]) && z.src[i] != '>' {
+					i++
+				}
+				val = z.src[start:i]
+			}
+		}
+
+		if t.typ != endTagToken {
+			t.attrs = append(t.attrs, htmlAttr{key, html.UnescapeString(val)})
+		}
+	}
+
+	if i > len(z.src) {
+		i = len(z.src)
+	}
+	z.pos = i
+
+	if t.typ == startTagToken && rawTextElements[t.data] {
+		z.raw = t.data
+	}
+
+	return t
+}
+
+// isASCIILetter reports whether c is an ASCII letter.
+func isASCIILetter(c byte) bool {
+	return (c >= 'a' && c <= 'z') || (c >= 'A' && c <= 'Z')
+}
+
+// isHTMLSpace reports whether c is HTML whitespace.
+func isHTMLSpace(c byte) bool {
+	return c == ' ' || c == '\t' || c == '\n' || c == '\r' || c == '\f'
+}
+
+// indexFold is strings.Index ignoring ASCII case. Only ASCII letters
+// are folded, so the offset it returns is valid in s; lower casing the
+// whole string would change the length of some other characters.
+func indexFold(s, substr string) int {
+	for i := 0; i+len(substr) <= len(s); i++ {
+		j := 0
+		for j < len(substr) && lowerASCII(s[i+j]) == lowerASCII(substr[j]) {
+			j++
+		}
+		if j == len(substr) {
+			return i
+		}
+	}
+
+	return -1
+}
+
+// lowerASCII returns the lower case of c if it is an ASCII letter.
+func lowerASCII(c byte) byte {
+	if 'A' <= c && c <= 'Z' {
+		return c + 'a' - 'A'
+	}
+
+	return c
+}
+
+// collapseSpace replaces runs of whitespace with a single space and
+// trims the ends.
+func collapseSpace(s string) string {
+	return strings.Join(strings.Fields(s), " ")
+}
+
+// windows1252 maps bytes 0x80 to 0x9F of windows-1252 to Unicode.
+// Other bytes are the same as ISO-8859-1.
+var windows1252 = [32]rune{
+	'€', '\u0081', '‚', 'ƒ', '„', '…', '†', '‡', 'ˆ', '‰', 'Š', '‹', 'Œ', '\u008d', 'Ž', '\u008f',
+	'\u0090', '‘', '’', '“', '”', '•', '–', '—', '˜', '™', 'š', '›', 'œ', '\u009d', 'ž', 'Ÿ',
+}
+
+// decodeHTML converts an HTML document to UTF-8. The charset comes
+// from the Content-Type header, a byte order mark or a <meta> tag, in
+// that order. UTF-8 and the Latin-1 family are supported; documents
+// in other charsets are decoded as UTF-8 with invalid bytes replaced.
+func decodeHTML(body []byte, contentType string) string {
+	var charset string
+	if _, params, err := mime.ParseMediaType(contentType); err == nil {
+		charset = params["charset"]
+	}
+
+	if bytes.HasPrefix(body, []byte("\xef\xbb\xbf")) {
+		body = body[3:]
+		charset = "utf-8"
+	}
+
+	if charset == "" {
+		charset = sniffCharset(body)
+	}
+
+	switch strings.ToLower(charset) {
+	case "iso-8859-1", "latin1", "latin-1", "windows-1252", "cp1252", "us-ascii", "ascii":
+		if utf8.Valid(body) && isASCII(body) {
+			return string(body)
+		}
+
+		var b strings.Builder
+		for _, c := range body {
+			if c >= 0x80 && c <= 0x9f {
+				b.WriteRune(windows1252[c-0x80])
+			} else {
+				b.WriteRune(rune(c))
+			}
+		}
+		return b.String()
+	}
+
+	return strings.ToValidUTF8(string(body), "�")
+}
+
+// isASCII reports whether b only holds ASCII characters.
+func isASCII(b []byte) bool {
+	for _, c := range b {
+		if c >= 0x80 {
+			return false
+		}
+	}
+
+	return true
+}
+
+// sniffCharset looks for a charset declared in a <meta> tag in the
+// first 1024 bytes of a document.
+func sniffCharset(body []byte) string {
+	if len(body) > 1024 {
+		body = body[:1024]
+	}
+
+	z := newHTMLTokenizer(string(body))
+	for {
+		t, ok := z.next()
+		if !ok {
+			return ""
+		}
+
+		if t.data != "meta" || t.typ == endTagToken {
+			continue
+		}
+
+		if cs := t.attr("charset"); cs != "" {
+			return strings.TrimSpace(cs)
+		}
+
+		if strings.EqualFold(t.attr("http-equiv"), "content-type") {
+			if _, params, err := mime.ParseMediaType(t.attr("content")); err == nil {
+				return params["charset"]
+			}
+		}
+	}
+}
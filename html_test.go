@@ -0,0 +1,105 @@
+package pinboard
+
+import (
+	"testing"
+)
+
+func TestHTMLTokenizer(t *testing.T) {
+	src := `<!DOCTYPE html><p class=intro id="a">Fish &amp; chips</P><br/>` +
+		`<script>if (a < b) { x = "</p>"; }</script><!-- note -->1 < 2 <a href='/x' title="unterminated>`
+
+	var got []htmlToken
+	z := newHTMLTokenizer(src)
+	for {
+		tok, ok := z.next()
+		if !ok {
+			break
+		}
+		got = append(got, tok)
+	}
+
+	expected := []htmlToken{
+		{typ: commentToken, data: "DOCTYPE html"},
+		{typ: startTagToken, data: "p"},
+		{typ: textToken, data: "Fish & chips"},
+		{typ: endTagToken, data: "p"},
+		{typ: selfClosingTagToken, data: "br"},
+		{typ: startTagToken, data: "script"},
+		{typ: textToken, data: `if (a < b) { x = "</p>"; }`},
+		{typ: endTagToken, data: "script"},
+		{typ: commentToken, data: " note "},
+		{typ: textToken, data: "1 "},
+		{typ: textToken, data: "< 2 "},
+		{typ: startTagToken, data: "a"},
+	}
+
+	if len(got) != len(expected) {
+		t.Fatalf("error: got %v tokens, expected %v: %v", len(got), len(expected), got)
+	}
+
+	for i, e := range expected {
+		if got[i].typ != e.typ || got[i].data != e.data {
+			t.Errorf("error: token %v: got %v %q, expected %v %q", i, got[i].typ, got[i].data, e.typ, e.data)
+		}
+	}
+
+	if got[1].attr("class") != "intro" || got[1].attr("id") != "a" {
+		t.Errorf("error: unexpected attributes %v", got[1].attrs)
+	}
+
+	if got[11].attr("href") != "/x" || got[11].attr("title") != "unterminated>" {
+		t.Errorf("error: unexpected attributes %v", got[11].attrs)
+	}
+}
+
+func TestDecodeHTML(t *testing.T) {
+	latin1 := []byte("<meta charset=\"iso-8859-1\"><p>caf\xe9 \x93quoted\x94</p>")
+
+	got := decodeHTML(latin1, "text/html")
+	expected := "<meta charset=\"iso-8859-1\"><p>café “quoted”</p>"
+	if got != expected {
+		t.Errorf("error: got %q, expected %q", got, expected)
+	}
+
+	got = decodeHTML([]byte("caf\xe9"), "text/html; charset=windows-1252")
+	if got != "café" {
+		t.Errorf("error: got %q, expected %q", got, "café")
+	}
+
+	got = decodeHTML([]byte("\xef\xbb\xbfcafé"), "")
+	if got != "café" {
+		t.Errorf("error: got %q, expected %q", got, "café")
+	}
+}
+
+func TestHTMLTokenizerNonASCII(t *testing.T) {
+	// Lower casing İ and the Kelvin sign changes their length in
+	// bytes, which must not move the end of the raw text.
+	src := "<TITLE>İstanbul İzmir K</TITLE><p>after</p>"
+
+	var got []htmlToken
+	z := newHTMLTokenizer(src)
+	for {
+		tok, ok := z.next()
+		if !ok {
+			break
+		}
+		got = append(got, tok)
+	}
+
+	if len(got) != 6 {
+		t.Fatalf("error: got %v tokens, expected 6: %v", len(got), got)
+	}
+
+	if got[1].data != "İstanbul İzmir K" {
+		t.Errorf("error: got title %q", got[1].data)
+	}
+
+	if got[2].typ != endTagToken || got[2].data != "title" || got[4].data != "after" {
+		t.Errorf("error: unexpected tokens %v", got[2:])
+	}
+
+	if i := indexFold("İİ</Title>", "</title>"); i != 4 {
+		t.Errorf("error: got %v, expected 4", i)
+	}
+}
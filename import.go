@@ -0,0 +1,145 @@
+package pinboard
+
+import (
+	"errors"
+	"strings"
+	"time"
+)
+
+// ImportOptions represents the optional arguments for importing
+// bookmarks.
+type ImportOptions struct {
+	// Time between calls to PostsAdd. Defaults to RateLimit.
+	Interval time.Duration
+
+	// Skip bookmarks whose URL is already in the account, or in
+	// Existing if given. URLs are compared after canonicalizing
+	// them with DefaultCanonicalizer.
+	SkipExisting bool
+
+	// Bookmarks already in the account. If nil and SkipExisting
+	// is set, they are fetched with PostsAll.
+	Existing []*Post
+
+	// Replace bookmarks that already exist instead of failing.
+	// Ignored if SkipExisting is set.
+	Replace bool
+
+	// Tags added to every imported bookmark, to find them later.
+	Tags []string
+
+	// Report what would happen without adding anything.
+	DryRun bool
+}
+
+// ImportResult is the outcome of importing a single bookmark: added,
+// skipped or failed.
+type ImportResult struct {
+	URL     string
+	Outcome Outcome
+	Err     error
+}
+
+// ImportReport describes what an import did.
+type ImportReport struct {
+	Results []ImportResult
+}
+
+// Count returns the number of bookmarks with the given outcome.
+func (r *ImportReport) Count(o Outcome) int {
+	var n int
+	for _, res := range r.Results {
+		if res.Outcome == o {
+			n++
+		}
+	}
+
+	return n
+}
+
+// Import adds bookmarks read by one of the importers in this package,
+// in order, spacing out calls to PostsAdd. A bookmark that fails to
+// import doesn't stop the others; its error is in the report.
+func Import(items []*PostsAddOptions, opt *ImportOptions) (*ImportReport, error) {
+	if opt == nil {
+		opt = &ImportOptions{}
+	}
+
+	existing := opt.Existing
+	if opt.SkipExisting && existing == nil {
+		var err error
+		existing, err = PostsAll(nil)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	seen := make(map[string]bool)
+	if opt.SkipExisting {
+		for _, p := range existing {
+			seen[importKey(p.Href.String())] = true
+		}
+	}
+
+	interval := opt.Interval
+	if interval == 0 {
+		interval = RateLimit
+	}
+	pc := &pacer{interval: interval}
+
+	report := &ImportReport{}
+	for _, item := range items {
+		result := ImportResult{URL: item.URL, Outcome: OutcomeAdded}
+
+		switch {
+		case item.URL == "":
+			result.Outcome = OutcomeFailed
+			result.Err = errors.New("error: missing url")
+		case item.Description == "":
+			result.Outcome = OutcomeFailed
+			result.Err = errors.New("error: missing description")
+		case opt.SkipExisting && seen[importKey(item.URL)]:
+			result.Outcome = OutcomeSkipped
+		}
+
+		if result.Outcome == OutcomeAdded {
+			seen[importKey(item.URL)] = true
+
+			add := *item
+			add.Tags = unionTags(item.Tags, opt.Tags)
+			add.Replace = opt.Replace && !opt.SkipExisting
+
+			if !opt.DryRun {
+				pc.wait()
+				err := PostsAdd(&add)
+				if err != nil {
+					result.Outcome = OutcomeFailed
+					result.Err = err
+				}
+			}
+		}
+
+		report.Results = append(report.Results, result)
+	}
+
+	return report, nil
+}
+
+// importKey returns the key URLs are compared by when skipping
+// existing bookmarks.
+func importKey(rawurl string) string {
+	c, err := DefaultCanonicalizer.Canonicalize(rawurl)
+	if err != nil {
+		return rawurl
+	}
+
+	return c
+}
+
+// tagName turns a folder or label name into a Pinboard tag, which
+// can't contain spaces or commas.
+func tagName(name string) string {
+	return strings.Join(strings.FieldsFunc(name, func(r rune) bool {
+		return r == ',' || r == ' ' || r == '\t' || r == '\n' || r == '\r'
+	}), "-")
+}
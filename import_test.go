@@ -0,0 +1,53 @@
+package pinboard
+
+import (
+	"testing"
+)
+
+func TestImportDryRun(t *testing.T) {
+	items := []*PostsAddOptions{
+		{URL: "https://golang.org/", Description: "Go"},
+		{URL: "http://www.example.com/?utm_source=feed", Description: "Example"},
+		{URL: "https://pinboard.in/", Description: "Pinboard"},
+		{URL: "https://pinboard.in", Description: "Pinboard again"},
+		{URL: "https://nodescription.com/"},
+	}
+
+	report, err := Import(items, &ImportOptions{
+		SkipExisting: true,
+		Existing:     []*Post{testPost("https://example.com/", "Example")},
+		Tags:         []string{"imported"},
+		DryRun:       true,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := []Outcome{OutcomeAdded, OutcomeSkipped, OutcomeAdded, OutcomeSkipped, OutcomeFailed}
+	for i, res := range report.Results {
+		if res.Outcome != expected[i] {
+			t.Errorf("error: got %v for %v, expected %v", res.Outcome, res.URL, expected[i])
+		}
+	}
+
+	if report.Count(OutcomeAdded) != 2 || report.Count(OutcomeFailed) != 1 {
+		t.Errorf("error: got %+v", report.Results)
+	}
+
+	if report.Results[4].Err == nil {
+		t.Error("error: expected missing description error")
+	}
+}
+
+func TestTagName(t *testing.T) {
+	for name, expected := range map[string]string{
+		"Bookmarks bar":  "Bookmarks-bar",
+		"a, b,c":         "a-b-c",
+		"  spaced  out ": "spaced-out",
+		"":               "",
+	} {
+		if got := tagName(name); got != expected {
+			t.Errorf("error: got %q, expected %q", got, expected)
+		}
+	}
+}
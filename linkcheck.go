@@ -0,0 +1,409 @@
+package pinboard
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"os"
+	"regexp"
+	"sort"
+	"sync"
+	"time"
+)
+
+// LinkStatus classifies the result of checking a bookmarked URL.
+type LinkStatus string
+
+const (
+	LinkOK          LinkStatus = "ok"
+	LinkRedirected  LinkStatus = "redirected"
+	LinkClientError LinkStatus = "client_error"
+	LinkServerError LinkStatus = "server_error"
+
+	// LinkUnreachable covers DNS failures, timeouts and refused
+	// connections.
+	LinkUnreachable LinkStatus = "unreachable"
+
+	// LinkSoft404 is a page that answers 200 OK but is really an
+	// error page, or a redirect from a deep link to the home page.
+	LinkSoft404 LinkStatus = "soft_404"
+
+	// LinkTooManyRedirects means the redirect limit was reached
+	// before a final page. It isn't counted as broken.
+	LinkTooManyRedirects LinkStatus = "too_many_redirects"
+)
+
+// errTooManyRedirects stops a LinkChecker's client at the redirect
+// limit.
+var errTooManyRedirects = errors.New("error: too many redirects")
+
+// LinkResult is the result of checking a single URL.
+type LinkResult struct {
+	URL        string     `json:"url"`
+	Status     LinkStatus `json:"status"`
+	StatusCode int        `json:"status_code,omitempty"`
+
+	// Redirects followed, in order, ending with the final URL.
+	Redirects []string `json:"redirects,omitempty"`
+
+	Error   string    `json:"error,omitempty"`
+	Checked time.Time `json:"checked"`
+
+	// The bookmark that was checked.
+	Post *Post `json:"-"`
+}
+
+// Broken reports whether the link is dead: a client error, an
+// unreachable host or a soft 404. Server errors are often temporary
+// and don't count.
+func (r *LinkResult) Broken() bool {
+	return r.Status == LinkClientError || r.Status == LinkUnreachable || r.Status == LinkSoft404
+}
+
+// LinkChecker checks whether bookmarked URLs still work.
+type LinkChecker struct {
+	// Fetcher used for requests. Its Client's redirect policy is
+	// replaced by the checker's own.
+	Fetcher *Fetcher
+
+	// Number of URLs checked at once. Defaults to 8.
+	Concurrency int
+
+	// Minimum delay between requests to the same host. Defaults to
+	// one second.
+	HostDelay time.Duration
+
+	// Maximum number of redirects to follow. Defaults to 10.
+	MaxRedirects int
+
+	// Download pages that answer 200 OK to look for error page
+	// titles. Redirects from a deep link to the home page are
+	// treated as soft 404s either way.
+	Soft404 bool
+
+	mu       sync.Mutex
+	nextSlot map[string]time.Time
+}
+
+// Check checks the URL of every post and returns the results in the
+// same order.
+func (c *LinkChecker) Check(ctx context.Context, posts []*Post) []LinkResult {
+	concurrency := c.Concurrency
+	if concurrency == 0 {
+		concurrency = 8
+	}
+
+	results := make([]LinkResult, len(posts))
+	jobs := make(chan int)
+
+	var wg sync.WaitGroup
+	for w := 0; w < concurrency; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range jobs {
+				results[i] = c.CheckURL(ctx, posts[i].Href.String())
+				results[i].Post = posts[i]
+			}
+		}()
+	}
+
+	for i := range posts {
+		jobs <- i
+	}
+	close(jobs)
+	wg.Wait()
+
+	return results
+}
+
+// CheckURL checks a single URL, trying HEAD first and falling back to
+// GET for servers that don't handle HEAD properly.
+func (c *LinkChecker) CheckURL(ctx context.Context, rawurl string) LinkResult {
+	result := LinkResult{URL: rawurl}
+
+	u, err := url.Parse(rawurl)
+	if err != nil {
+		result.Status = LinkClientError
+		result.Error = err.Error()
+		result.Checked = time.Now().UTC()
+		return result
+	}
+
+	res, redirects, err := c.request(ctx, http.MethodHead, u)
+	if err != nil || res.StatusCode >= 400 || (c.Soft404 && res.StatusCode == http.StatusOK) {
+		res, redirects, err = c.request(ctx, http.MethodGet, u)
+	}
+
+	result.Checked = time.Now().UTC()
+	result.Redirects = redirects
+	if errors.Is(err, errTooManyRedirects) {
+		result.Status = LinkTooManyRedirects
+		result.Error = err.Error()
+		return result
+	}
+	if err != nil {
+		result.Status = LinkUnreachable
+		result.Error = err.Error()
+		return result
+	}
+
+	result.StatusCode = res.StatusCode
+	switch {
+	case res.StatusCode >= 500:
+		result.Status = LinkServerError
+	case res.StatusCode >= 400:
+		result.Status = LinkClientError
+	case len(redirects) > 0 && redirectedHome(u, redirects[len(redirects)-1]):
+		result.Status = LinkSoft404
+	case res.title != "" && errorTitle.MatchString(res.title):
+		result.Status = LinkSoft404
+	case len(redirects) > 0:
+		result.Status = LinkRedirected
+	default:
+		result.Status = LinkOK
+	}
+
+	return result
+}
+
+// errorTitle matches page titles of typical error pages.
+var errorTitle = regexp.MustCompile(`(?i)\b(404|not found|page not found|no longer available|does not exist)\b`)
+
+// redirectedHome reports whether a link to a page deeper than the
+// home page ended up on the home page.
+func redirectedHome(from *url.URL, final string) bool {
+	to, err := url.Parse(final)
+	if err != nil {
+		return false
+	}
+
+	deep := from.Path != "" && from.Path != "/"
+	home := to.Path == "" || to.Path == "/"
+
+	return deep && home && to.RawQuery == ""
+}
+
+// checkResponse is the part of a response a LinkChecker looks at.
+type checkResponse struct {
+	StatusCode int
+	title      string
+}
+
+// request makes a single request, waiting for the host's politeness
+// delay first, and returns the response along with the redirects
+// followed.
+func (c *LinkChecker) request(ctx context.Context, method string, u *url.URL) (*checkResponse, []string, error) {
+	max := c.MaxRedirects
+	if max == 0 {
+		max = 10
+	}
+
+	var f Fetcher
+	if c.Fetcher != nil {
+		f = *c.Fetcher
+	}
+
+	client := http.DefaultClient
+	if f.Client != nil {
+		client = f.Client
+	}
+
+	var redirects []string
+	tracking := *client
+	tracking.CheckRedirect = func(req *http.Request, via []*http.Request) error {
+		if len(redirects) >= max {
+			return errTooManyRedirects
+		}
+		c.wait(ctx, req.URL.Host)
+		redirects = append(redirects, req.URL.String())
+		return nil
+	}
+
+	f.Client = &tracking
+	if method == http.MethodGet && f.MaxBytes == 0 {
+		f.MaxBytes = 64 << 10
+	}
+
+	c.wait(ctx, u.Host)
+
+	var res checkResponse
+	if method == http.MethodHead {
+		timeout := f.Timeout
+		if timeout == 0 {
+			timeout = DefaultFetchTimeout
+		}
+		ctx, cancel := context.WithTimeout(ctx, timeout)
+		defer cancel()
+
+		req, err := http.NewRequestWithContext(ctx, method, u.String(), nil)
+		if err != nil {
+			return nil, nil, err
+		}
+		if f.UserAgent != "" {
+			req.Header.Set("User-Agent", f.UserAgent)
+		}
+
+		r, err := tracking.Do(req)
+		if err != nil {
+			return nil, redirects, err
+		}
+		r.Body.Close()
+		res.StatusCode = r.StatusCode
+
+		return &res, redirects, nil
+	}
+
+	p, err := f.get(ctx, u.String())
+	if err != nil {
+		return nil, redirects, err
+	}
+	res.StatusCode = p.status
+	if p.status == http.StatusOK {
+		res.title = ParseMetadata(p.url, p.body, p.contentType).Title
+	}
+
+	return &res, redirects, nil
+}
+
+// wait blocks until the politeness delay for host has passed.
+func (c *LinkChecker) wait(ctx context.Context, host string) {
+	delay := c.HostDelay
+	if delay == 0 {
+		delay = time.Second
+	}
+
+	c.mu.Lock()
+	if c.nextSlot == nil {
+		c.nextSlot = make(map[string]time.Time)
+	}
+	now := time.Now()
+	slot := c.nextSlot[host]
+	if slot.Before(now) {
+		slot = now
+	}
+	c.nextSlot[host] = slot.Add(delay)
+	c.mu.Unlock()
+
+	select {
+	case <-time.After(time.Until(slot)):
+	case <-ctx.Done():
+	}
+}
+
+// LinkHistory stores the results of link checks over time so trends
+// can be reported.
+type LinkHistory struct {
+	path string
+
+	// Results of every check, keyed by URL, oldest first.
+	Checks map[string][]LinkResult `json:"checks"`
+}
+
+// OpenLinkHistory loads the history stored at path, or returns an
+// empty history if the file doesn't exist.
+func OpenLinkHistory(path string) (*LinkHistory, error) {
+	h := &LinkHistory{path: path, Checks: make(map[string][]LinkResult)}
+
+	data, err := ioutil.ReadFile(path)
+	if err != nil && !os.IsNotExist(err) {
+		return nil, err
+	}
+
+	if len(data) > 0 {
+		err = json.Unmarshal(data, h)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return h, nil
+}
+
+// Record adds results to the history.
+func (h *LinkHistory) Record(results []LinkResult) {
+	for _, r := range results {
+		h.Checks[r.URL] = append(h.Checks[r.URL], r)
+	}
+}
+
+// Save writes the history to the file it was opened from.
+func (h *LinkHistory) Save() error {
+	data, err := json.Marshal(h)
+	if err != nil {
+		return err
+	}
+
+	return writeFileAtomic(h.path, data, 0600)
+}
+
+// Latest returns the number of URLs with each status as of their most
+// recent check.
+func (h *LinkHistory) Latest() map[LinkStatus]int {
+	counts := make(map[LinkStatus]int)
+	for _, checks := range h.Checks {
+		counts[checks[len(checks)-1].Status]++
+	}
+
+	return counts
+}
+
+// LinkTrend is the number of URLs with each status found on one day.
+type LinkTrend struct {
+	Day    time.Time
+	Counts map[LinkStatus]int
+}
+
+// Trend returns the status counts for each day that had checks,
+// oldest first.
+func (h *LinkHistory) Trend() []LinkTrend {
+	days := make(map[time.Time]map[LinkStatus]int)
+	for _, checks := range h.Checks {
+		for _, r := range checks {
+			day := r.Checked.UTC().Truncate(24 * time.Hour)
+			if days[day] == nil {
+				days[day] = make(map[LinkStatus]int)
+			}
+			days[day][r.Status]++
+		}
+	}
+
+	var trend []LinkTrend
+	for day, counts := range days {
+		trend = append(trend, LinkTrend{Day: day, Counts: counts})
+	}
+
+	sort.Slice(trend, func(i, j int) bool {
+		return trend[i].Day.Before(trend[j].Day)
+	})
+
+	return trend
+}
+
+// TagBroken adds tag, for example "dead", to the bookmark of every
+// broken result using read-modify-write PostsAdd calls, waiting
+// RateLimit between them. Bookmarks that already have the tag are
+// left alone.
+func TagBroken(results []LinkResult, tag string) error {
+	pc := &pacer{interval: RateLimit}
+
+	for _, r := range results {
+		if !r.Broken() || r.Post == nil || hasTagFold(r.Post.Tags, tag) {
+			continue
+		}
+
+		opt := r.Post.addOptions()
+		opt.Tags = append(opt.Tags, tag)
+
+		pc.wait()
+		err := PostsAdd(opt)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,153 @@
+package pinboard
+
+import (
+	"context"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestLinkChecker(t *testing.T) {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/", func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/" {
+			http.NotFound(w, r)
+		}
+	})
+	mux.HandleFunc("/ok", func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, "<title>Fine</title>")
+	})
+	mux.HandleFunc("/moved", func(w http.ResponseWriter, r *http.Request) {
+		http.Redirect(w, r, "/ok", http.StatusMovedPermanently)
+	})
+	mux.HandleFunc("/gone", func(w http.ResponseWriter, r *http.Request) {
+		http.Redirect(w, r, "/", http.StatusFound)
+	})
+	mux.HandleFunc("/broken", func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusInternalServerError)
+	})
+	mux.HandleFunc("/nohead", func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == http.MethodHead {
+			w.WriteHeader(http.StatusMethodNotAllowed)
+		}
+	})
+	mux.HandleFunc("/soft", func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, "<title>Page Not Found</title>")
+	})
+
+	srv := httptest.NewServer(mux)
+	defer srv.Close()
+
+	closed := httptest.NewServer(mux)
+	closed.Close()
+
+	posts := []*Post{
+		testPost(srv.URL+"/ok", "OK"),
+		testPost(srv.URL+"/moved", "Moved"),
+		testPost(srv.URL+"/gone", "Gone"),
+		testPost(srv.URL+"/missing", "Missing"),
+		testPost(srv.URL+"/broken", "Broken"),
+		testPost(srv.URL+"/nohead", "No HEAD"),
+		testPost(srv.URL+"/soft", "Soft"),
+		testPost(closed.URL+"/ok", "Closed"),
+	}
+
+	c := &LinkChecker{HostDelay: time.Millisecond, Soft404: true}
+	results := c.Check(context.Background(), posts)
+
+	expected := []LinkStatus{
+		LinkOK,
+		LinkRedirected,
+		LinkSoft404,
+		LinkClientError,
+		LinkServerError,
+		LinkOK,
+		LinkSoft404,
+		LinkUnreachable,
+	}
+
+	for i, status := range expected {
+		if results[i].Status != status {
+			t.Errorf("error: %s: got %v, expected %v", results[i].URL, results[i].Status, status)
+		}
+
+		if results[i].Post != posts[i] {
+			t.Errorf("error: %s: expected result to hold its post", results[i].URL)
+		}
+	}
+
+	if len(results[1].Redirects) != 1 || results[1].Redirects[0] != srv.URL+"/ok" {
+		t.Errorf("error: got redirects %v", results[1].Redirects)
+	}
+
+	dir, err := ioutil.TempDir("", "linkcheck")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	h, err := OpenLinkHistory(filepath.Join(dir, "history.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	h.Record(results)
+	err = h.Save()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	h, err = OpenLinkHistory(filepath.Join(dir, "history.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	latest := h.Latest()
+	if latest[LinkSoft404] != 2 || latest[LinkOK] != 2 {
+		t.Errorf("error: unexpected latest counts %v", latest)
+	}
+
+	trend := h.Trend()
+	if len(trend) != 1 || trend[0].Counts[LinkUnreachable] != 1 {
+		t.Errorf("error: unexpected trend %v", trend)
+	}
+}
+
+func TestLinkCheckerRedirectLimit(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var n int
+		fmt.Sscanf(r.URL.Path, "/r/%d", &n)
+		if n > 0 {
+			http.Redirect(w, r, fmt.Sprintf("/r/%d", n-1), http.StatusFound)
+		}
+	}))
+	defer srv.Close()
+
+	tests := []struct {
+		path      string
+		status    LinkStatus
+		redirects int
+	}{
+		{"/r/3", LinkRedirected, 3},
+		{"/r/4", LinkTooManyRedirects, 3},
+	}
+
+	c := &LinkChecker{HostDelay: time.Millisecond, MaxRedirects: 3}
+	for _, test := range tests {
+		r := c.CheckURL(context.Background(), srv.URL+test.path)
+		if r.Status != test.status {
+			t.Errorf("error: %s: got %v, expected %v", test.path, r.Status, test.status)
+		}
+		if len(r.Redirects) != test.redirects {
+			t.Errorf("error: %s: got %d redirects, expected %d", test.path, len(r.Redirects), test.redirects)
+		}
+		if r.Broken() {
+			t.Errorf("error: %s: expected link not to be broken", test.path)
+		}
+	}
+}
@@ -0,0 +1,241 @@
+package pinboard
+
+import (
+	"fmt"
+	"time"
+)
+
+// ConflictPolicy decides what happens to a URL that is bookmarked in
+// both the source and destination accounts of a migration.
+type ConflictPolicy int
+
+const (
+	// KeepSource replaces the destination bookmark with the source
+	// bookmark.
+	KeepSource ConflictPolicy = iota
+
+	// KeepDestination leaves the destination bookmark untouched.
+	KeepDestination
+
+	// MergeTags keeps the destination bookmark but adds any tags
+	// only found on the source bookmark.
+	MergeTags
+
+	// NewestWins keeps whichever bookmark has the most recent
+	// Time.
+	NewestWins
+)
+
+// Outcome describes what a migration did with a single bookmark.
+type Outcome string
+
+const (
+	// OutcomeAdded means the bookmark was new to the destination
+	// and was added.
+	OutcomeAdded Outcome = "added"
+
+	// OutcomeReplaced means the destination bookmark was
+	// overwritten with the source bookmark.
+	OutcomeReplaced Outcome = "replaced"
+
+	// OutcomeMerged means tags only found on the source bookmark
+	// were added to the destination bookmark.
+	OutcomeMerged Outcome = "merged"
+
+	// OutcomeSkipped means nothing was written, because the
+	// conflict policy kept the destination bookmark as it was.
+	OutcomeSkipped Outcome = "skipped"
+
+	// OutcomeFailed means writing the bookmark failed. The error
+	// is in the result.
+	OutcomeFailed Outcome = "failed"
+)
+
+// MigrateOptions represents the arguments for migrating bookmarks
+// from one account to another.
+type MigrateOptions struct {
+	// Required: API token of the account to read from.
+	Source string
+
+	// Required: API token of the account to write to.
+	Destination string
+
+	// How to handle URLs bookmarked in both accounts. Default is
+	// KeepSource.
+	Conflict ConflictPolicy
+
+	// Delay between calls to PostsAdd. Defaults to RateLimit.
+	Interval time.Duration
+
+	// Report what would happen without writing anything.
+	DryRun bool
+}
+
+// MigrateResult is the outcome of migrating a single bookmark.
+type MigrateResult struct {
+	URL     string
+	Outcome Outcome
+	Err     error
+}
+
+// MigrateReport describes what a migration did.
+type MigrateReport struct {
+	// One result per source bookmark.
+	Posts []MigrateResult
+
+	// Notes read from the source account. The Pinboard API has no
+	// way to create notes, so they are returned here, including
+	// their body text, to be copied by hand.
+	Notes []*Note
+}
+
+// Count returns the number of bookmarks with the given outcome.
+func (r *MigrateReport) Count(o Outcome) int {
+	var n int
+	for _, p := range r.Posts {
+		if p.Outcome == o {
+			n++
+		}
+	}
+
+	return n
+}
+
+// migrateAPI holds the API calls made by Migrate, so that tests can
+// replace them.
+type migrateAPI struct {
+	postsAll  func(opt *PostsAllOptions) ([]*Post, error)
+	notesList func() ([]*Note, error)
+	notesID   func(id string) (*Note, error)
+	postsAdd  func(opt *PostsAddOptions) error
+}
+
+var defaultMigrateAPI = migrateAPI{
+	postsAll:  PostsAll,
+	notesList: NotesList,
+	notesID:   NotesID,
+	postsAdd:  PostsAdd,
+}
+
+// Migrate copies every bookmark from the Source account to the
+// Destination account, keeping original timestamps and privacy flags.
+//
+// Since API calls use the token set with SetToken, Migrate switches
+// the token while it runs and restores it before returning. Don't
+// make other API calls concurrently.
+func Migrate(opt *MigrateOptions) (*MigrateReport, error) {
+	return migrate(opt, defaultMigrateAPI)
+}
+
+func migrate(opt *MigrateOptions, api migrateAPI) (*MigrateReport, error) {
+	if opt.Source == "" || opt.Destination == "" {
+		return nil, fmt.Errorf("error: missing source or destination token")
+	}
+
+	token := pinboardToken
+	defer SetToken(token)
+
+	interval := opt.Interval
+	if interval == 0 {
+		interval = RateLimit
+	}
+	pc := &pacer{interval: interval}
+
+	SetToken(opt.Source)
+	src, err := api.postsAll(nil)
+	if err != nil {
+		return nil, err
+	}
+
+	var report MigrateReport
+
+	list, err := api.notesList()
+	if err != nil {
+		return nil, err
+	}
+
+	for _, n := range list {
+		pc.wait()
+		note, err := api.notesID(n.ID)
+		if err != nil {
+			return nil, err
+		}
+
+		report.Notes = append(report.Notes, note)
+	}
+
+	SetToken(opt.Destination)
+	dst, err := api.postsAll(nil)
+	if err != nil {
+		return nil, err
+	}
+
+	existing := make(map[string]*Post)
+	for _, p := range dst {
+		existing[p.Href.String()] = p
+	}
+
+	for _, p := range src {
+		add, outcome := resolveConflict(p, existing[p.Href.String()], opt.Conflict)
+		result := MigrateResult{URL: p.Href.String(), Outcome: outcome}
+
+		if add != nil && !opt.DryRun {
+			pc.wait()
+			err := api.postsAdd(add)
+			if err != nil {
+				result.Outcome = OutcomeFailed
+				result.Err = err
+			}
+		}
+
+		report.Posts = append(report.Posts, result)
+	}
+
+	return &report, nil
+}
+
+// resolveConflict decides how to write the source bookmark src given
+// the destination bookmark dst, which is nil if the URL isn't
+// bookmarked in the destination. It returns nil options if nothing
+// should be written.
+func resolveConflict(src, dst *Post, policy ConflictPolicy) (*PostsAddOptions, Outcome) {
+	add := src.addOptions()
+
+	if dst == nil {
+		return add, OutcomeAdded
+	}
+
+	switch policy {
+	case KeepDestination:
+		return nil, OutcomeSkipped
+	case MergeTags:
+		merged := dst.addOptions()
+		merged.Tags = unionTags(merged.Tags, add.Tags)
+		if len(merged.Tags) == len(cleanTags(dst.Tags)) {
+			return nil, OutcomeSkipped
+		}
+		return merged, OutcomeMerged
+	case NewestWins:
+		if !src.Time.After(dst.Time) {
+			return nil, OutcomeSkipped
+		}
+	}
+
+	return add, OutcomeReplaced
+}
+
+// unionTags returns the tags in a followed by any tags in b that
+// aren't in a.
+func unionTags(a, b []string) []string {
+	seen := make(map[string]bool)
+
+	var tags []string
+	for _, t := range append(cleanTags(a), cleanTags(b)...) {
+		if !seen[t] {
+			seen[t] = true
+			tags = append(tags, t)
+		}
+	}
+
+	return tags
+}
@@ -0,0 +1,144 @@
+package pinboard
+
+import (
+	"fmt"
+	"testing"
+	"time"
+)
+
+func TestResolveConflict(t *testing.T) {
+	src := testPost("https://golang.org/", "Go", "go", "lang")
+	src.Shared = true
+	src.Time = src.Time.Add(time.Hour)
+
+	dst := testPost("https://golang.org/", "Golang", "go", "code")
+
+	add, outcome := resolveConflict(src, nil, KeepDestination)
+	if outcome != OutcomeAdded || !add.Dt.Equal(src.Time) || !add.Shared {
+		t.Errorf("error: expected added with original time and shared flag, got %v %v", outcome, add)
+	}
+
+	add, outcome = resolveConflict(src, dst, KeepSource)
+	if outcome != OutcomeReplaced || add.Description != "Go" {
+		t.Errorf("error: got %v, expected %v", outcome, OutcomeReplaced)
+	}
+
+	add, outcome = resolveConflict(src, dst, KeepDestination)
+	if outcome != OutcomeSkipped || add != nil {
+		t.Errorf("error: got %v, expected %v", outcome, OutcomeSkipped)
+	}
+
+	add, outcome = resolveConflict(src, dst, MergeTags)
+	if outcome != OutcomeMerged || add.Description != "Golang" || len(add.Tags) != 3 {
+		t.Errorf("error: got %v %v, expected merged tags", outcome, add)
+	}
+
+	add, outcome = resolveConflict(src, dst, NewestWins)
+	if outcome != OutcomeReplaced || add.Description != "Go" {
+		t.Errorf("error: got %v, expected %v", outcome, OutcomeReplaced)
+	}
+
+	_, outcome = resolveConflict(dst, src, NewestWins)
+	if outcome != OutcomeSkipped {
+		t.Errorf("error: got %v, expected %v", outcome, OutcomeSkipped)
+	}
+}
+
+// testMigrateAPI returns an API for migrate that serves the posts of
+// each account, keyed by token, and records the posts added.
+func testMigrateAPI(accounts map[string][]*Post, added *[]string) migrateAPI {
+	return migrateAPI{
+		postsAll: func(opt *PostsAllOptions) ([]*Post, error) {
+			return accounts[pinboardToken], nil
+		},
+		notesList: func() ([]*Note, error) {
+			return []*Note{{ID: "1", Title: "Reading"}}, nil
+		},
+		notesID: func(id string) (*Note, error) {
+			return &Note{ID: id, Title: "Reading", Text: []byte("Books")}, nil
+		},
+		postsAdd: func(opt *PostsAddOptions) error {
+			if pinboardToken != "dst" {
+				return fmt.Errorf("error: added to %q", pinboardToken)
+			}
+			*added = append(*added, opt.URL)
+			return nil
+		},
+	}
+}
+
+func TestMigrate(t *testing.T) {
+	token := pinboardToken
+	defer SetToken(token)
+	SetToken("mine")
+
+	newer := testPost("https://golang.org/", "Go", "go")
+	newer.Time = newer.Time.Add(time.Hour)
+
+	accounts := map[string][]*Post{
+		"src": {
+			newer,
+			testPost("https://pinboard.in/", "Pinboard"),
+			testPost("https://example.com/", "Example"),
+		},
+		"dst": {
+			testPost("https://golang.org/", "Golang"),
+			testPost("https://pinboard.in/", "Pinboard"),
+		},
+	}
+
+	for _, test := range []struct {
+		policy   ConflictPolicy
+		dryRun   bool
+		added    []string
+		outcomes []Outcome
+	}{
+		{KeepSource, false,
+			[]string{"https://golang.org/", "https://pinboard.in/", "https://example.com/"},
+			[]Outcome{OutcomeReplaced, OutcomeReplaced, OutcomeAdded}},
+		{KeepDestination, false,
+			[]string{"https://example.com/"},
+			[]Outcome{OutcomeSkipped, OutcomeSkipped, OutcomeAdded}},
+		{MergeTags, false,
+			[]string{"https://golang.org/", "https://example.com/"},
+			[]Outcome{OutcomeMerged, OutcomeSkipped, OutcomeAdded}},
+		{NewestWins, false,
+			[]string{"https://golang.org/", "https://example.com/"},
+			[]Outcome{OutcomeReplaced, OutcomeSkipped, OutcomeAdded}},
+		{KeepSource, true,
+			nil,
+			[]Outcome{OutcomeReplaced, OutcomeReplaced, OutcomeAdded}},
+	} {
+		var added []string
+		report, err := migrate(&MigrateOptions{
+			Source:      "src",
+			Destination: "dst",
+			Conflict:    test.policy,
+			Interval:    time.Nanosecond,
+			DryRun:      test.dryRun,
+		}, testMigrateAPI(accounts, &added))
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if fmt.Sprint(added) != fmt.Sprint(test.added) {
+			t.Errorf("error: policy %v: got added %v, expected %v", test.policy, added, test.added)
+		}
+
+		var outcomes []Outcome
+		for _, r := range report.Posts {
+			outcomes = append(outcomes, r.Outcome)
+		}
+		if fmt.Sprint(outcomes) != fmt.Sprint(test.outcomes) {
+			t.Errorf("error: policy %v: got outcomes %v, expected %v", test.policy, outcomes, test.outcomes)
+		}
+
+		if len(report.Notes) != 1 || string(report.Notes[0].Text) != "Books" {
+			t.Errorf("error: expected note with its text, got %v", report.Notes)
+		}
+
+		if pinboardToken != "mine" {
+			t.Errorf("error: got token %q, expected it to be restored", pinboardToken)
+		}
+	}
+}
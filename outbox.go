@@ -0,0 +1,318 @@
+package pinboard
+
+import (
+	"encoding/json"
+	"errors"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"os"
+	"sync"
+	"time"
+)
+
+// OpKind is the kind of write operation held in an Outbox.
+type OpKind string
+
+const (
+	OpPostsAdd    OpKind = "posts_add"
+	OpPostsDelete OpKind = "posts_delete"
+	OpTagsRename  OpKind = "tags_rename"
+	OpTagsDelete  OpKind = "tags_delete"
+)
+
+// Op is a queued write operation.
+type Op struct {
+	// Sequence number of the operation within its Outbox.
+	ID int64 `json:"id"`
+
+	Kind OpKind `json:"kind"`
+
+	// Arguments for OpPostsAdd.
+	Add *PostsAddOptions `json:"add,omitempty"`
+
+	// URL for OpPostsDelete.
+	URL string `json:"url,omitempty"`
+
+	// Tag for OpTagsDelete, or the old tag name for OpTagsRename.
+	Tag string `json:"tag,omitempty"`
+
+	// New tag name for OpTagsRename.
+	New string `json:"new,omitempty"`
+
+	// Time the operation was queued.
+	Queued time.Time `json:"queued"`
+}
+
+// url returns the bookmark URL the operation applies to, if any.
+func (op *Op) url() string {
+	switch op.Kind {
+	case OpPostsAdd:
+		return op.Add.URL
+	case OpPostsDelete:
+		return op.URL
+	}
+
+	return ""
+}
+
+// outboxAPI holds the API calls made by Replay, so that tests can
+// replace them.
+type outboxAPI struct {
+	postsAdd    func(opt *PostsAddOptions) error
+	postsDelete func(url string) error
+	tagsRename  func(old, new string) error
+	tagsDelete  func(tag string) error
+}
+
+var defaultOutboxAPI = outboxAPI{
+	postsAdd:    PostsAdd,
+	postsDelete: PostsDelete,
+	tagsRename:  TagsRename,
+	tagsDelete:  TagsDelete,
+}
+
+// do makes the API call for the operation.
+func (op *Op) do(api outboxAPI) error {
+	switch op.Kind {
+	case OpPostsAdd:
+		return api.postsAdd(op.Add)
+	case OpPostsDelete:
+		err := api.postsDelete(op.URL)
+		// The bookmark is gone either way.
+		var re *ResultError
+		if errors.As(err, &re) && re.Result == "item not found" {
+			return nil
+		}
+		return err
+	case OpTagsRename:
+		return api.tagsRename(op.Tag, op.New)
+	case OpTagsDelete:
+		return api.tagsDelete(op.Tag)
+	}
+
+	return errors.New("error: unknown operation " + string(op.Kind))
+}
+
+// Outbox is a durable, on-disk queue of write operations. Operations
+// are accepted while the API is unreachable and sent in order by
+// Replay once it is available again.
+type Outbox struct {
+	// OnFailure is called with operations that the API rejects,
+	// for example because of invalid arguments. They are removed
+	// from the queue. Optional.
+	OnFailure func(op Op, err error)
+
+	// Delay between API calls during Replay. Defaults to
+	// RateLimit.
+	Interval time.Duration
+
+	path string
+	api  outboxAPI
+
+	// Held while replaying, so that concurrent calls to Replay
+	// don't send the same operation twice.
+	replay sync.Mutex
+
+	mu     sync.Mutex
+	ops    []Op
+	lastID int64
+}
+
+// OpenOutbox opens the outbox stored at path, creating it if it
+// doesn't exist.
+func OpenOutbox(path string) (*Outbox, error) {
+	o := &Outbox{path: path, api: defaultOutboxAPI}
+
+	data, err := ioutil.ReadFile(path)
+	if err != nil && !os.IsNotExist(err) {
+		return nil, err
+	}
+
+	if len(data) > 0 {
+		err = json.Unmarshal(data, &o.ops)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	for _, op := range o.ops {
+		if op.ID > o.lastID {
+			o.lastID = op.ID
+		}
+	}
+
+	return o, nil
+}
+
+// PostsAdd queues a bookmark to be added.
+func (o *Outbox) PostsAdd(opt *PostsAddOptions) error {
+	if opt.URL == "" {
+		return errors.New("error: missing url")
+	}
+
+	if opt.Description == "" {
+		return errors.New("error: missing description")
+	}
+
+	return o.enqueue(Op{Kind: OpPostsAdd, Add: opt})
+}
+
+// PostsDelete queues a bookmark to be deleted by url.
+func (o *Outbox) PostsDelete(url string) error {
+	return o.enqueue(Op{Kind: OpPostsDelete, URL: url})
+}
+
+// TagsRename queues a tag to be renamed.
+func (o *Outbox) TagsRename(old, new string) error {
+	return o.enqueue(Op{Kind: OpTagsRename, Tag: old, New: new})
+}
+
+// TagsDelete queues a tag to be deleted.
+func (o *Outbox) TagsDelete(tag string) error {
+	return o.enqueue(Op{Kind: OpTagsDelete, Tag: tag})
+}
+
+// Ops returns a copy of the queued operations in the order they will
+// be sent.
+func (o *Outbox) Ops() []Op {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+
+	return append([]Op(nil), o.ops...)
+}
+
+// Len returns the number of queued operations.
+func (o *Outbox) Len() int {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+
+	return len(o.ops)
+}
+
+// enqueue appends op to the queue and saves it. Any earlier add or
+// delete of the same URL is superseded by op and dropped.
+func (o *Outbox) enqueue(op Op) error {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+
+	o.lastID++
+	op.ID = o.lastID
+	op.Queued = time.Now()
+
+	ops := make([]Op, 0, len(o.ops)+1)
+	for _, queued := range o.ops {
+		if u := op.url(); u != "" && queued.url() == u {
+			continue
+		}
+		ops = append(ops, queued)
+	}
+	ops = append(ops, op)
+
+	err := o.save(ops)
+	if err != nil {
+		o.lastID--
+		return err
+	}
+	o.ops = ops
+
+	return nil
+}
+
+// remove drops the operation with the given ID from the queue and
+// saves it.
+func (o *Outbox) remove(id int64) error {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+
+	ops := make([]Op, 0, len(o.ops))
+	for _, op := range o.ops {
+		if op.ID != id {
+			ops = append(ops, op)
+		}
+	}
+
+	err := o.save(ops)
+	if err != nil {
+		return err
+	}
+	o.ops = ops
+
+	return nil
+}
+
+// save atomically writes ops to the outbox file.
+func (o *Outbox) save(ops []Op) error {
+	data, err := json.Marshal(ops)
+	if err != nil {
+		return err
+	}
+
+	return writeFileAtomic(o.path, data, 0600)
+}
+
+// Replay sends the queued operations in order, removing each one once
+// it succeeds or the API rejects it. On any other error, such as the
+// API being unreachable, rate limiting or an invalid token, it stops
+// and returns the error, leaving that operation and the rest of the
+// queue in place to be retried later.
+func (o *Outbox) Replay() error {
+	o.replay.Lock()
+	defer o.replay.Unlock()
+
+	interval := o.Interval
+	if interval == 0 {
+		interval = RateLimit
+	}
+	pc := &pacer{interval: interval}
+
+	for {
+		o.mu.Lock()
+		if len(o.ops) == 0 {
+			o.mu.Unlock()
+			return nil
+		}
+		op := o.ops[0]
+		o.mu.Unlock()
+
+		pc.wait()
+		err := op.do(o.api)
+		if err != nil && !rejected(err) {
+			return err
+		}
+
+		if err != nil && o.OnFailure != nil {
+			o.OnFailure(op, err)
+		}
+
+		err = o.remove(op.ID)
+		if err != nil {
+			return err
+		}
+	}
+}
+
+// rejected reports whether err means the API refused the operation
+// itself, with a result code or 400 Bad Request, so that sending it
+// again can't succeed.
+func rejected(err error) bool {
+	var re *ResultError
+	if errors.As(err, &re) {
+		return true
+	}
+
+	var se *StatusError
+	return errors.As(err, &se) && se.StatusCode == http.StatusBadRequest
+}
+
+// transient reports whether err is worth retrying later: network
+// errors, rate limiting and server errors.
+func transient(err error) bool {
+	var se *StatusError
+	if errors.As(err, &se) {
+		return se.StatusCode == http.StatusTooManyRequests || se.StatusCode >= 500
+	}
+
+	var ne net.Error
+	return errors.As(err, &ne)
+}
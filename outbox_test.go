@@ -0,0 +1,300 @@
+package pinboard
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestOutbox(t *testing.T) {
+	dir, err := ioutil.TempDir("", "outbox")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	path := filepath.Join(dir, "outbox.json")
+
+	o, err := OpenOutbox(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = o.PostsAdd(&PostsAddOptions{URL: "https://golang.org/", Description: "Go"})
+	if err != nil {
+		t.Error(err)
+	}
+
+	err = o.PostsAdd(&PostsAddOptions{URL: "https://golang.org/"})
+	if err == nil {
+		t.Error("error: expected missing description error")
+	}
+
+	err = o.TagsRename("golang", "go")
+	if err != nil {
+		t.Error(err)
+	}
+
+	err = o.PostsAdd(&PostsAddOptions{URL: "https://pinboard.in/", Description: "Pinboard"})
+	if err != nil {
+		t.Error(err)
+	}
+
+	// Supersedes the add of golang.org.
+	err = o.PostsDelete("https://golang.org/")
+	if err != nil {
+		t.Error(err)
+	}
+
+	// Reopen to make sure the queue was persisted.
+	o, err = OpenOutbox(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ops := o.Ops()
+	expected := []OpKind{OpTagsRename, OpPostsAdd, OpPostsDelete}
+	if len(ops) != len(expected) {
+		t.Fatalf("error: got %v, expected %v ops", len(ops), len(expected))
+	}
+
+	for i, kind := range expected {
+		if ops[i].Kind != kind {
+			t.Errorf("error: got %v, expected %v", ops[i].Kind, kind)
+		}
+	}
+
+	if ops[2].URL != "https://golang.org/" || ops[2].ID != 4 {
+		t.Errorf("error: unexpected op %v", ops[2])
+	}
+}
+
+func TestOutboxReplay(t *testing.T) {
+	dir, err := ioutil.TempDir("", "outbox")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	path := filepath.Join(dir, "outbox.json")
+
+	o, err := OpenOutbox(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	o.PostsAdd(&PostsAddOptions{URL: "https://golang.org/", Description: "Go"})
+	o.PostsDelete("https://gone.com/")
+	o.PostsAdd(&PostsAddOptions{URL: "https://invalid.com/", Description: "Invalid"})
+	o.TagsDelete("old")
+	o.TagsRename("golang", "go")
+
+	var calls []string
+	limited := true
+	api := outboxAPI{
+		postsAdd: func(opt *PostsAddOptions) error {
+			calls = append(calls, "add "+opt.URL)
+			if opt.URL == "https://invalid.com/" {
+				return &ResultError{Result: "something went wrong"}
+			}
+			return nil
+		},
+		postsDelete: func(url string) error {
+			calls = append(calls, "delete "+url)
+			return &ResultError{Result: "item not found"}
+		},
+		tagsRename: func(old, new string) error {
+			calls = append(calls, "rename "+old)
+			return nil
+		},
+		tagsDelete: func(tag string) error {
+			calls = append(calls, "delete "+tag)
+			if limited {
+				return &StatusError{StatusCode: 429}
+			}
+			return nil
+		},
+	}
+
+	var failed []Op
+	o.api = api
+	o.Interval = time.Nanosecond
+	o.OnFailure = func(op Op, err error) {
+		failed = append(failed, op)
+	}
+
+	err = o.Replay()
+	var se *StatusError
+	if !errors.As(err, &se) {
+		t.Fatalf("error: got %v, expected replay to stop on rate limiting", err)
+	}
+
+	expected := []string{"add https://golang.org/", "delete https://gone.com/", "add https://invalid.com/", "delete old"}
+	if len(calls) != len(expected) {
+		t.Fatalf("error: got calls %v, expected %v", calls, expected)
+	}
+	for i := range expected {
+		if calls[i] != expected[i] {
+			t.Errorf("error: got call %q, expected %q", calls[i], expected[i])
+		}
+	}
+
+	if len(failed) != 1 || failed[0].Add.URL != "https://invalid.com/" {
+		t.Errorf("error: expected only invalid.com to fail, got %v", failed)
+	}
+
+	// The rate limited operation and the rest of the queue are kept.
+	o, err = OpenOutbox(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ops := o.Ops()
+	if len(ops) != 2 || ops[0].Kind != OpTagsDelete || ops[1].Kind != OpTagsRename {
+		t.Fatalf("error: unexpected ops left %v", ops)
+	}
+
+	calls, limited = nil, false
+	o.api = api
+	o.Interval = time.Nanosecond
+
+	err = o.Replay()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if o.Len() != 0 || len(calls) != 2 {
+		t.Errorf("error: got %v ops left and calls %v, expected an empty queue", o.Len(), calls)
+	}
+}
+
+func TestOutboxReplayKeepsOps(t *testing.T) {
+	dir, err := ioutil.TempDir("", "outbox")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	for i, test := range []struct {
+		err  error
+		kept bool
+	}{
+		{&StatusError{StatusCode: 401}, true},
+		{&StatusError{StatusCode: 403}, true},
+		{&StatusError{StatusCode: 503}, true},
+		{&json.SyntaxError{}, true},
+		{&StatusError{StatusCode: 400}, false},
+		{&ResultError{Result: "missing url"}, false},
+	} {
+		path := filepath.Join(dir, fmt.Sprintf("outbox%d.json", i))
+		o, err := OpenOutbox(path)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		for _, tag := range []string{"a", "b"} {
+			err = o.TagsDelete(tag)
+			if err != nil {
+				t.Fatal(err)
+			}
+		}
+
+		var failed []Op
+		o.OnFailure = func(op Op, err error) {
+			failed = append(failed, op)
+		}
+		o.Interval = time.Nanosecond
+		o.api.tagsDelete = func(tag string) error {
+			if tag == "a" {
+				return test.err
+			}
+			return nil
+		}
+
+		err = o.Replay()
+
+		o, _ = OpenOutbox(path)
+		if test.kept {
+			if err == nil || len(failed) != 0 || o.Len() != 2 || o.Ops()[0].Tag != "a" {
+				t.Errorf("error: %v: got %v, %v failed and %v ops, expected the queue to be kept", test.err, err, len(failed), o.Len())
+			}
+			continue
+		}
+
+		if err != nil || len(failed) != 1 || o.Len() != 0 {
+			t.Errorf("error: %v: got %v, %v failed and %v ops, expected the op to be discarded", test.err, err, len(failed), o.Len())
+		}
+	}
+}
+
+func TestOutboxReplayConcurrent(t *testing.T) {
+	dir, err := ioutil.TempDir("", "outbox")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	o, err := OpenOutbox(filepath.Join(dir, "outbox.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, tag := range []string{"a", "b", "c"} {
+		err = o.TagsDelete(tag)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	var mu sync.Mutex
+	sent := make(map[string]int)
+	o.Interval = time.Nanosecond
+	o.api.tagsDelete = func(tag string) error {
+		time.Sleep(time.Millisecond)
+		mu.Lock()
+		sent[tag]++
+		mu.Unlock()
+		return nil
+	}
+
+	var wg sync.WaitGroup
+	for i := 0; i < 2; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			err := o.Replay()
+			if err != nil {
+				t.Error(err)
+			}
+		}()
+	}
+	wg.Wait()
+
+	if len(sent) != 3 || sent["a"] != 1 || sent["b"] != 1 || sent["c"] != 1 {
+		t.Errorf("error: got %v, expected each op to be sent once", sent)
+	}
+}
+
+func TestTransient(t *testing.T) {
+	if !transient(&StatusError{StatusCode: 429}) {
+		t.Error("error: expected 429 to be transient")
+	}
+
+	if !transient(&StatusError{StatusCode: 503}) {
+		t.Error("error: expected 503 to be transient")
+	}
+
+	if transient(&StatusError{StatusCode: 401}) {
+		t.Error("error: expected 401 to be permanent")
+	}
+
+	if transient(errors.New("missing url")) {
+		t.Error("error: expected result code error to be permanent")
+	}
+}
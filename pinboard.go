@@ -59,8 +59,32 @@ var (
 	}
 
 	pinboardToken = ""
+
+	// RateLimit is the minimum delay between consecutive API calls
+	// made by bulk operations such as Plan.Apply. Pinboard asks
+	// clients to make no more than one call every three seconds.
+	RateLimit = 3 * time.Second
 )
 
+// pacer spaces out API calls made in a loop so that bulk operations
+// stay within RateLimit.
+type pacer struct {
+	interval time.Duration
+	last     time.Time
+}
+
+// wait blocks until at least interval has passed since the previous
+// call to wait.
+func (p *pacer) wait() {
+	if !p.last.IsZero() {
+		if d := p.interval - time.Since(p.last); d > 0 {
+			time.Sleep(d)
+		}
+	}
+
+	p.last = time.Now()
+}
+
 // get checks if endpoint is a valid Pinboard API endpoint and then
 // constructs a valid endpoint URL including the required 'auth_token'
 // and 'format' values along with any optional arguments found in the
@@ -113,7 +137,7 @@ func get(endpoint string, options interface{}) (body []byte, err error) {
 	// whether the API token is not set (401) or if we somehow
 	// managed to request an invalid endpoint (500).
 	if res.StatusCode != http.StatusOK {
-		return nil, fmt.Errorf("error: http %d", res.StatusCode)
+		return nil, &StatusError{StatusCode: res.StatusCode}
 	}
 
 	body, err = ioutil.ReadAll(res.Body)
@@ -124,6 +148,26 @@ func get(endpoint string, options interface{}) (body []byte, err error) {
 	return body, nil
 }
 
+// StatusError is returned when the API responds with a HTTP status
+// code other than 200 OK, for example 429 when rate limited.
+type StatusError struct {
+	StatusCode int
+}
+
+func (e *StatusError) Error() string {
+	return fmt.Sprintf("error: http %d", e.StatusCode)
+}
+
+// ResultError is returned when the API responds with a result code
+// other than "done", for example "item not found".
+type ResultError struct {
+	Result string
+}
+
+func (e *ResultError) Error() string {
+	return e.Result
+}
+
 // values expects a *MethodOptions struct and encodes the fields into
 // url.Values.
 func values(i interface{}) (url.Values, error) {
@@ -0,0 +1,325 @@
+package pinboard
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"html"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"sort"
+	"strings"
+	"time"
+)
+
+// Verdict is a pipeline stage's decision about a bookmark.
+type Verdict int
+
+const (
+	// VerdictUnchanged means the stage left the bookmark as it was.
+	VerdictUnchanged Verdict = iota
+
+	// VerdictChanged means the stage modified the bookmark.
+	VerdictChanged
+
+	// VerdictSkip means the bookmark should not be added. No further
+	// stages run.
+	VerdictSkip
+)
+
+// String returns the name of the verdict.
+func (v Verdict) String() string {
+	switch v {
+	case VerdictUnchanged:
+		return "unchanged"
+	case VerdictChanged:
+		return "changed"
+	case VerdictSkip:
+		return "skip"
+	}
+
+	return "unknown"
+}
+
+// Decision reports what a pipeline stage did.
+type Decision struct {
+	// Name of the stage.
+	Stage string
+
+	Verdict Verdict
+
+	// Human readable explanation.
+	Message string
+}
+
+// Stage is a step of a Pipeline. Process may modify opt in place.
+type Stage interface {
+	Process(opt *PostsAddOptions) (Decision, error)
+}
+
+// StageFunc adapts a function to a Stage.
+type StageFunc func(opt *PostsAddOptions) (Decision, error)
+
+// Process calls f(opt).
+func (f StageFunc) Process(opt *PostsAddOptions) (Decision, error) {
+	return f(opt)
+}
+
+// Pipeline runs a bookmark through a series of stages before it is
+// added.
+type Pipeline struct {
+	Stages []Stage
+}
+
+// NewPipeline returns a pipeline of the given stages, run in order.
+func NewPipeline(stages ...Stage) *Pipeline {
+	return &Pipeline{Stages: stages}
+}
+
+// PipelineResult holds the decision of each stage that ran.
+type PipelineResult struct {
+	Decisions []Decision
+
+	// Whether a stage decided the bookmark should not be added.
+	Skipped bool
+}
+
+// Run passes opt through every stage, modifying it in place. It stops
+// early if a stage returns an error or a VerdictSkip verdict.
+func (p *Pipeline) Run(opt *PostsAddOptions) (*PipelineResult, error) {
+	var result PipelineResult
+
+	for _, s := range p.Stages {
+		d, err := s.Process(opt)
+		if err != nil {
+			return &result, err
+		}
+
+		result.Decisions = append(result.Decisions, d)
+		if d.Verdict == VerdictSkip {
+			result.Skipped = true
+			break
+		}
+	}
+
+	return &result, nil
+}
+
+// PostsAdd runs opt through the pipeline and adds the bookmark unless
+// a stage skipped it.
+func (p *Pipeline) PostsAdd(opt *PostsAddOptions) (*PipelineResult, error) {
+	result, err := p.Run(opt)
+	if err != nil || result.Skipped {
+		return result, err
+	}
+
+	return result, PostsAdd(opt)
+}
+
+// CanonicalizeStage rewrites the URL with c, or DefaultCanonicalizer
+// if c is nil.
+func CanonicalizeStage(c *Canonicalizer) Stage {
+	if c == nil {
+		c = DefaultCanonicalizer
+	}
+
+	return StageFunc(func(opt *PostsAddOptions) (Decision, error) {
+		d := Decision{Stage: "canonicalize"}
+
+		u, err := c.Canonicalize(opt.URL)
+		if err != nil {
+			return d, err
+		}
+
+		if u != opt.URL {
+			d.Verdict = VerdictChanged
+			d.Message = fmt.Sprintf("rewrote %s to %s", opt.URL, u)
+			opt.URL = u
+		}
+
+		return d, nil
+	})
+}
+
+// DedupeStage skips bookmarks whose URL has the same canonical form,
+// under c or DefaultCanonicalizer if c is nil, as one of the existing
+// posts. Bookmarks with Replace set are let through.
+func DedupeStage(existing []*Post, c *Canonicalizer) Stage {
+	if c == nil {
+		c = DefaultCanonicalizer
+	}
+
+	seen := make(map[string]string)
+	for _, p := range existing {
+		seen[c.canonical(p.Href)] = p.Href.String()
+	}
+
+	return StageFunc(func(opt *PostsAddOptions) (Decision, error) {
+		d := Decision{Stage: "dedupe"}
+
+		u, err := c.Canonicalize(opt.URL)
+		if err != nil {
+			return d, err
+		}
+
+		if dup, ok := seen[u]; ok && !opt.Replace {
+			d.Verdict = VerdictSkip
+			d.Message = "already bookmarked as " + dup
+		}
+
+		return d, nil
+	})
+}
+
+// DomainTagStage adds tags based on the URL's host. Rules map a
+// domain, which also matches its subdomains, to the tags to add, as
+// in "github.com" to []string{"code"}.
+func DomainTagStage(rules map[string][]string) Stage {
+	return StageFunc(func(opt *PostsAddOptions) (Decision, error) {
+		d := Decision{Stage: "domain-tags"}
+
+		u, err := url.Parse(opt.URL)
+		if err != nil {
+			return d, err
+		}
+
+		var domains []string
+		for domain := range rules {
+			domains = append(domains, domain)
+		}
+		sort.Strings(domains)
+
+		had := make(map[string]bool)
+		for _, t := range opt.Tags {
+			had[t] = true
+		}
+
+		var added []string
+		for _, domain := range domains {
+			if !onSite(u.Hostname(), domain) {
+				continue
+			}
+
+			for _, t := range cleanTags(rules[domain]) {
+				if !had[t] {
+					had[t] = true
+					added = append(added, t)
+				}
+			}
+		}
+
+		if len(added) > 0 {
+			opt.Tags = unionTags(opt.Tags, added)
+			d.Verdict = VerdictChanged
+			d.Message = "tagged " + strings.Join(added, " ")
+		}
+
+		return d, nil
+	})
+}
+
+// TagAliasStage replaces tags with their preferred spelling. Aliases
+// map an alias to the tag that should be used instead, as in
+// "golang" to "go". Matching is case insensitive.
+func TagAliasStage(aliases map[string]string) Stage {
+	lower := make(map[string]string)
+	for alias, tag := range aliases {
+		lower[strings.ToLower(alias)] = tag
+	}
+
+	return StageFunc(func(opt *PostsAddOptions) (Decision, error) {
+		d := Decision{Stage: "tag-aliases"}
+
+		var tags, renamed []string
+		for _, t := range opt.Tags {
+			if tag, ok := lower[strings.ToLower(t)]; ok {
+				renamed = append(renamed, t+"→"+tag)
+				t = tag
+			}
+			tags = append(tags, t)
+		}
+
+		tags = unionTags(tags, nil)
+		if len(renamed) > 0 || len(tags) != len(opt.Tags) {
+			d.Verdict = VerdictChanged
+			d.Message = "removed duplicate tags"
+			if len(renamed) > 0 {
+				d.Message = "renamed " + strings.Join(renamed, " ")
+			}
+		}
+		opt.Tags = tags
+
+		return d, nil
+	})
+}
+
+// TitleStage fills in a missing Description by fetching the page
+// title with fetch. If fetch is nil the page is downloaded and its
+// <title> used.
+func TitleStage(fetch func(rawurl string) (string, error)) Stage {
+	if fetch == nil {
+		fetch = fetchTitle
+	}
+
+	return StageFunc(func(opt *PostsAddOptions) (Decision, error) {
+		d := Decision{Stage: "title"}
+
+		if opt.Description != "" {
+			return d, nil
+		}
+
+		title, err := fetch(opt.URL)
+		if err != nil {
+			return d, err
+		}
+
+		if title == "" {
+			return d, errors.New("error: no title found for " + opt.URL)
+		}
+
+		opt.Description = title
+		d.Verdict = VerdictChanged
+		d.Message = "fetched title " + title
+
+		return d, nil
+	})
+}
+
+// fetchTitle downloads up to 512KB of a page and returns the text of
+// its <title> element.
+func fetchTitle(rawurl string) (string, error) {
+	client := &http.Client{Timeout: 10 * time.Second}
+
+	res, err := client.Get(rawurl)
+	if err != nil {
+		return "", err
+	}
+	defer res.Body.Close()
+
+	if res.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("error: %s: http %d", rawurl, res.StatusCode)
+	}
+
+	body, err := ioutil.ReadAll(io.LimitReader(res.Body, 512<<10))
+	if err != nil {
+		return "", err
+	}
+
+	lower := bytes.ToLower(body)
+	start := bytes.Index(lower, []byte("<title"))
+	if start < 0 {
+		return "", nil
+	}
+
+	open := bytes.IndexByte(lower[start:], '>')
+	end := bytes.Index(lower[start:], []byte("</title"))
+	if open < 0 || end < open {
+		return "", nil
+	}
+
+	title := string(body[start+open+1 : start+end])
+
+	return strings.Join(strings.Fields(html.UnescapeString(title)), " "), nil
+}
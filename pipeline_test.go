@@ -0,0 +1,94 @@
+package pinboard
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestPipeline(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, "<html><head><TITLE>\n  Gophers &amp; Friends </TITLE></head></html>")
+	}))
+	defer srv.Close()
+
+	existing := []*Post{testPost("http://www.example.com/seen/", "Seen")}
+
+	p := NewPipeline(
+		CanonicalizeStage(&Canonicalizer{StripParams: []string{"utm_*"}}),
+		DedupeStage(existing, nil),
+		DomainTagStage(map[string][]string{"127.0.0.1": {"local"}}),
+		TagAliasStage(map[string]string{"golang": "go"}),
+		TitleStage(nil),
+	)
+
+	opt := &PostsAddOptions{
+		URL:  srv.URL + "/?utm_source=feed",
+		Tags: []string{"Golang", "go"},
+	}
+
+	result, err := p.Run(opt)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if result.Skipped || len(result.Decisions) != 5 {
+		t.Fatalf("error: unexpected result %v", result)
+	}
+
+	for i, verdict := range []Verdict{VerdictChanged, VerdictUnchanged, VerdictChanged, VerdictChanged, VerdictChanged} {
+		if result.Decisions[i].Verdict != verdict {
+			t.Errorf("error: %s: got %v, expected %v", result.Decisions[i].Stage, result.Decisions[i].Verdict, verdict)
+		}
+	}
+
+	if opt.URL != srv.URL+"/" {
+		t.Errorf("error: got url %v, expected %v", opt.URL, srv.URL+"/")
+	}
+
+	if opt.Description != "Gophers & Friends" {
+		t.Errorf("error: got title %q", opt.Description)
+	}
+
+	if len(opt.Tags) != 2 || opt.Tags[0] != "go" || opt.Tags[1] != "local" {
+		t.Errorf("error: got tags %v, expected [go local]", opt.Tags)
+	}
+
+	result, err = p.Run(&PostsAddOptions{URL: "https://example.com/seen", Description: "Seen"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !result.Skipped || len(result.Decisions) != 2 {
+		t.Errorf("error: expected duplicate to be skipped, got %v", result)
+	}
+}
+
+func TestDomainTagStage(t *testing.T) {
+	stage := DomainTagStage(map[string][]string{"github.com": {"a", "code"}})
+
+	opt := &PostsAddOptions{URL: "https://gist.github.com/", Tags: []string{"a", "a", "a"}}
+	d, err := stage.Process(opt)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if d.Verdict != VerdictChanged || d.Message != "tagged code" {
+		t.Errorf("error: got %v %q, expected changed with tagged code", d.Verdict, d.Message)
+	}
+
+	if len(opt.Tags) != 2 || opt.Tags[0] != "a" || opt.Tags[1] != "code" {
+		t.Errorf("error: got tags %v, expected [a code]", opt.Tags)
+	}
+
+	opt = &PostsAddOptions{URL: "https://github.com/", Tags: []string{"code", "code", "a"}}
+	d, err = stage.Process(opt)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if d.Verdict != VerdictUnchanged || len(opt.Tags) != 3 {
+		t.Errorf("error: got %v with tags %v, expected the bookmark to be left alone", d.Verdict, opt.Tags)
+	}
+}
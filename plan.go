@@ -0,0 +1,620 @@
+package pinboard
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// DesiredPost represents a bookmark as declared in a desired-state
+// file.
+type DesiredPost struct {
+	// Required: The URL of the item.
+	URL string `json:"url"`
+
+	// Required: Title of the item.
+	Description string `json:"description"`
+
+	// Description of the item.
+	Extended string `json:"extended,omitempty"`
+
+	// Tags of the item.
+	Tags []string `json:"tags,omitempty"`
+
+	// If the bookmark is public.
+	Shared bool `json:"shared,omitempty"`
+
+	// If the bookmark is marked to read later.
+	Toread bool `json:"toread,omitempty"`
+}
+
+// ReadDesired decodes a JSON array of DesiredPosts.
+func ReadDesired(r io.Reader) ([]DesiredPost, error) {
+	var desired []DesiredPost
+	err := json.NewDecoder(r).Decode(&desired)
+	if err != nil {
+		return nil, err
+	}
+
+	return desired, checkDesired(desired)
+}
+
+// ReadDesiredYAML decodes a YAML sequence of DesiredPosts, with the
+// same keys as the JSON form. Rather than depend on a YAML package it
+// reads only the subset of YAML that such a file needs:
+//
+//	# Comments, blank lines and a "---" line are ignored.
+//	- url: https://golang.org/
+//	  description: "Go: the language"  # plain, "double" or 'single' quoted
+//	  tags: [go, lang]                # or a block sequence, or "go lang"
+//	  shared: true                    # true or false
+//	- url: https://pinboard.in/
+//	  description: Pinboard
+//	  tags:
+//	    - bookmarks
+//	    - social
+//
+// Values must fit on one line. Block scalars (| and >), nested
+// mappings, anchors and tags are not supported and give an error.
+func ReadDesiredYAML(r io.Reader) ([]DesiredPost, error) {
+	data, err := ioutil.ReadAll(r)
+	if err != nil {
+		return nil, err
+	}
+
+	lines := strings.Split(strings.ReplaceAll(string(data), "\r\n", "\n"), "\n")
+
+	var desired []DesiredPost
+	seqIndent, keyIndent := -1, -1
+	for i := 0; i < len(lines); i++ {
+		text := strings.TrimLeft(lines[i], " ")
+		indent := len(lines[i]) - len(text)
+
+		if yamlBlank(text) || (indent == 0 && text == "---") {
+			continue
+		}
+
+		if strings.HasPrefix(text, "\t") {
+			return nil, fmt.Errorf("error: line %d: tabs can't be used for indentation", i+1)
+		}
+
+		if seqIndent < 0 {
+			seqIndent = indent
+		}
+
+		if indent == seqIndent && yamlItem(text) {
+			desired = append(desired, DesiredPost{})
+
+			rest := strings.TrimLeft(text[1:], " ")
+			keyIndent = indent + len(text) - len(rest)
+			if yamlBlank(rest) {
+				continue
+			}
+			text, indent = rest, keyIndent
+		}
+
+		if len(desired) == 0 || indent != keyIndent {
+			return nil, fmt.Errorf("error: line %d: expected a sequence of mappings", i+1)
+		}
+
+		colon := strings.Index(text, ":")
+		if colon <= 0 || (colon+1 < len(text) && text[colon+1] != ' ') {
+			return nil, fmt.Errorf("error: line %d: expected key: value", i+1)
+		}
+
+		key := strings.TrimSpace(text[:colon])
+		value := strings.TrimSpace(text[colon+1:])
+
+		var v yamlValue
+		switch {
+		case yamlBlank(value):
+			v.list, i, err = yamlSequence(lines, i, keyIndent)
+			v.isList = v.list != nil
+		case value[0] == '[':
+			v.list, err = yamlFlow(value)
+			v.isList = true
+		case strings.IndexByte("|>{&*!", value[0]) >= 0:
+			err = fmt.Errorf("unsupported value %q", value)
+		default:
+			v.s, err = yamlPlain(value)
+		}
+		if err != nil {
+			return nil, fmt.Errorf("error: line %d: %s", i+1, err)
+		}
+
+		err = v.set(&desired[len(desired)-1], key)
+		if err != nil {
+			return nil, fmt.Errorf("error: line %d: %s", i+1, err)
+		}
+	}
+
+	return desired, checkDesired(desired)
+}
+
+// checkDesired makes sure every desired post has a URL and a title,
+// and that no URL is declared twice.
+func checkDesired(desired []DesiredPost) error {
+	seen := make(map[string]bool)
+	for _, d := range desired {
+		if d.URL == "" {
+			return errors.New("error: missing url")
+		}
+
+		if d.Description == "" {
+			return fmt.Errorf("error: missing description for %s", d.URL)
+		}
+
+		if seen[d.URL] {
+			return fmt.Errorf("error: duplicate url %s", d.URL)
+		}
+		seen[d.URL] = true
+	}
+
+	return nil
+}
+
+// yamlValue is the value of a key in a desired-state YAML file,
+// either a scalar or a sequence of scalars.
+type yamlValue struct {
+	s      string
+	list   []string
+	isList bool
+}
+
+// set stores the value in the field of d named by key. Unknown keys
+// are ignored, as they are by ReadDesired.
+func (v yamlValue) set(d *DesiredPost, key string) error {
+	if v.isList && key != "tags" {
+		return fmt.Errorf("%s can't be a sequence", key)
+	}
+
+	var err error
+	switch key {
+	case "url":
+		d.URL = v.s
+	case "description":
+		d.Description = v.s
+	case "extended":
+		d.Extended = v.s
+	case "tags":
+		d.Tags = v.list
+		if !v.isList {
+			d.Tags = strings.Fields(v.s)
+		}
+	case "shared":
+		d.Shared, err = strconv.ParseBool(v.s)
+	case "toread":
+		d.Toread, err = strconv.ParseBool(v.s)
+	}
+
+	return err
+}
+
+// yamlBlank reports whether a line, or what is left of it, is empty
+// or a comment.
+func yamlBlank(s string) bool {
+	s = strings.TrimSpace(s)
+	return s == "" || s[0] == '#'
+}
+
+// yamlItem reports whether a line starts a sequence item.
+func yamlItem(s string) bool {
+	return s == "-" || strings.HasPrefix(s, "- ")
+}
+
+// yamlSequence reads the block sequence of scalars whose items follow
+// line i, and returns it with the number of its last line. A sequence
+// may be indented the same as the key it belongs to. It returns nil if
+// no items follow.
+func yamlSequence(lines []string, i, indent int) ([]string, int, error) {
+	var list []string
+	for ; i+1 < len(lines); i++ {
+		text := strings.TrimLeft(lines[i+1], " ")
+		n := len(lines[i+1]) - len(text)
+
+		if yamlBlank(text) {
+			continue
+		}
+
+		if n < indent || !yamlItem(text) {
+			break
+		}
+
+		v, err := yamlPlain(text[1:])
+		if err != nil {
+			return nil, i + 1, err
+		}
+		list = append(list, v)
+	}
+
+	return list, i, nil
+}
+
+// yamlPlain reads a scalar that makes up the rest of a line, quoted
+// or not, with any trailing comment removed.
+func yamlPlain(s string) (string, error) {
+	v, rest, err := yamlScalar(s, "")
+	if err != nil {
+		return "", err
+	}
+
+	if !yamlBlank(rest) {
+		return "", fmt.Errorf("unexpected %q", strings.TrimSpace(rest))
+	}
+
+	return v, nil
+}
+
+// yamlFlow reads a flow sequence of scalars, such as [go, "web dev"].
+func yamlFlow(s string) ([]string, error) {
+	list := []string{}
+	s = s[1:]
+	for {
+		s = strings.TrimLeft(s, " ")
+		if strings.HasPrefix(s, "]") {
+			break
+		}
+
+		v, rest, err := yamlScalar(s, ",]")
+		if err != nil {
+			return nil, err
+		}
+		list = append(list, v)
+
+		rest = strings.TrimLeft(rest, " ")
+		switch {
+		case strings.HasPrefix(rest, ","):
+			s = rest[1:]
+			continue
+		case strings.HasPrefix(rest, "]"):
+			s = rest
+		default:
+			return nil, errors.New("unterminated flow sequence")
+		}
+		break
+	}
+
+	if !yamlBlank(s[1:]) {
+		return nil, fmt.Errorf("unexpected %q", strings.TrimSpace(s[1:]))
+	}
+
+	return list, nil
+}
+
+// yamlScalar reads a scalar from the start of s and returns it with
+// the rest of s. An unquoted scalar ends at a comment or at any of the
+// bytes in stop. Double quoted scalars take Go's escapes, which cover
+// the ones commonly used in YAML.
+func yamlScalar(s, stop string) (string, string, error) {
+	s = strings.TrimLeft(s, " ")
+
+	switch {
+	case strings.HasPrefix(s, `"`):
+		for i := 1; i < len(s); i++ {
+			switch s[i] {
+			case '\\':
+				i++
+			case '"':
+				v, err := strconv.Unquote(s[:i+1])
+				return v, s[i+1:], err
+			}
+		}
+		return "", "", errors.New("unterminated string")
+
+	case strings.HasPrefix(s, "'"):
+		var b strings.Builder
+		for i := 1; i < len(s); i++ {
+			if s[i] != '\'' {
+				b.WriteByte(s[i])
+				continue
+			}
+			if i+1 < len(s) && s[i+1] == '\'' {
+				b.WriteByte('\'')
+				i++
+				continue
+			}
+			return b.String(), s[i+1:], nil
+		}
+		return "", "", errors.New("unterminated string")
+	}
+
+	end := len(s)
+	if i := strings.IndexAny(s, stop); i >= 0 {
+		end = i
+	}
+	if i := strings.Index(s, " #"); i >= 0 && i < end {
+		end = i
+	}
+	if strings.HasPrefix(s, "#") {
+		end = 0
+	}
+
+	v := strings.TrimSpace(s[:end])
+	if v == "~" || v == "null" {
+		v = ""
+	}
+
+	return v, s[end:], nil
+}
+
+// Action is the kind of change a Plan makes to a bookmark.
+type Action int
+
+const (
+	// ActionAdd creates a bookmark that doesn't exist yet.
+	ActionAdd Action = iota
+
+	// ActionUpdate replaces a bookmark whose fields differ from
+	// the desired state.
+	ActionUpdate
+
+	// ActionDelete removes a bookmark that isn't in the desired
+	// state. Only planned in prune mode.
+	ActionDelete
+)
+
+// String returns the symbol used for the action when printing a plan.
+func (a Action) String() string {
+	switch a {
+	case ActionAdd:
+		return "+"
+	case ActionUpdate:
+		return "~"
+	case ActionDelete:
+		return "-"
+	}
+
+	return "?"
+}
+
+// Change is a single planned change to a bookmark, keyed by URL.
+type Change struct {
+	Action Action
+
+	// URL of the bookmark.
+	URL string
+
+	// Desired state of the bookmark. Nil for deletes.
+	Desired *DesiredPost
+
+	// Live bookmark at the time the plan was made. Nil for adds.
+	Current *Post
+
+	// Names of the fields that differ. Only set for updates.
+	Fields []string
+}
+
+// Plan is the set of changes needed to make the account match a
+// desired state.
+type Plan struct {
+	Changes []Change
+}
+
+// PlanPosts compares the desired bookmarks against the live ones,
+// usually the result of PostsAll, and returns the changes needed to
+// make them match. Live bookmarks that aren't in desired are left
+// alone unless prune is true, in which case they are deleted.
+func PlanPosts(desired []DesiredPost, current []*Post, prune bool) *Plan {
+	live := make(map[string]*Post)
+	for _, p := range current {
+		live[p.Href.String()] = p
+	}
+
+	var plan Plan
+	wanted := make(map[string]bool)
+	for i := range desired {
+		d := &desired[i]
+		wanted[d.URL] = true
+
+		p, ok := live[d.URL]
+		if !ok {
+			plan.Changes = append(plan.Changes, Change{
+				Action:  ActionAdd,
+				URL:     d.URL,
+				Desired: d,
+			})
+			continue
+		}
+
+		if fields := diffFields(d, p); len(fields) > 0 {
+			plan.Changes = append(plan.Changes, Change{
+				Action:  ActionUpdate,
+				URL:     d.URL,
+				Desired: d,
+				Current: p,
+				Fields:  fields,
+			})
+		}
+	}
+
+	if prune {
+		for _, p := range current {
+			u := p.Href.String()
+			if !wanted[u] {
+				plan.Changes = append(plan.Changes, Change{
+					Action:  ActionDelete,
+					URL:     u,
+					Current: p,
+				})
+			}
+		}
+	}
+
+	sort.SliceStable(plan.Changes, func(i, j int) bool {
+		return plan.Changes[i].URL < plan.Changes[j].URL
+	})
+
+	return &plan
+}
+
+// diffFields returns the names of the fields where the live post p
+// differs from the desired post d.
+func diffFields(d *DesiredPost, p *Post) []string {
+	var fields []string
+
+	if d.Description != p.Description {
+		fields = append(fields, "description")
+	}
+
+	if d.Extended != string(p.Extended) {
+		fields = append(fields, "extended")
+	}
+
+	if !sameTags(d.Tags, p.Tags) {
+		fields = append(fields, "tags")
+	}
+
+	if d.Shared != p.Shared {
+		fields = append(fields, "shared")
+	}
+
+	if d.Toread != p.Toread {
+		fields = append(fields, "toread")
+	}
+
+	return fields
+}
+
+// cleanTags drops the empty tags that Pinboard returns for untagged
+// bookmarks.
+func cleanTags(tags []string) []string {
+	var clean []string
+	for _, t := range tags {
+		if t != "" {
+			clean = append(clean, t)
+		}
+	}
+
+	return clean
+}
+
+// sameTags reports whether a and b hold the same tags, ignoring
+// order.
+func sameTags(a, b []string) bool {
+	a, b = cleanTags(a), cleanTags(b)
+	if len(a) != len(b) {
+		return false
+	}
+
+	as := append([]string(nil), a...)
+	bs := append([]string(nil), b...)
+	sort.Strings(as)
+	sort.Strings(bs)
+	for i := range as {
+		if as[i] != bs[i] {
+			return false
+		}
+	}
+
+	return true
+}
+
+// Empty reports whether the plan has no changes.
+func (p *Plan) Empty() bool {
+	return len(p.Changes) == 0
+}
+
+// String returns a human readable summary of the plan, one change per
+// line.
+func (p *Plan) String() string {
+	var b strings.Builder
+
+	var add, update, del int
+	for _, c := range p.Changes {
+		switch c.Action {
+		case ActionAdd:
+			add++
+			fmt.Fprintf(&b, "%s %s (%s)\n", c.Action, c.URL, c.Desired.Description)
+		case ActionUpdate:
+			update++
+			fmt.Fprintf(&b, "%s %s [%s]\n", c.Action, c.URL, strings.Join(c.Fields, ", "))
+		case ActionDelete:
+			del++
+			fmt.Fprintf(&b, "%s %s\n", c.Action, c.URL)
+		}
+	}
+
+	fmt.Fprintf(&b, "%d to add, %d to update, %d to delete\n", add, update, del)
+
+	return b.String()
+}
+
+// Apply makes the planned changes with PostsAdd and PostsDelete,
+// waiting RateLimit between calls. Before a bookmark is updated or
+// deleted its change detection signature (Meta) is checked again, so
+// a bookmark edited after the plan was made is not overwritten. Apply
+// stops at the first error.
+func (p *Plan) Apply() error {
+	pc := &pacer{interval: RateLimit}
+
+	for _, c := range p.Changes {
+		if c.Action != ActionAdd {
+			pc.wait()
+			err := checkDrift(c)
+			if err != nil {
+				return err
+			}
+		}
+
+		pc.wait()
+
+		var err error
+		switch c.Action {
+		case ActionAdd, ActionUpdate:
+			err = PostsAdd(c.addOptions())
+		case ActionDelete:
+			err = PostsDelete(c.URL)
+		}
+		if err != nil {
+			return fmt.Errorf("error: %s: %s", c.URL, err)
+		}
+	}
+
+	return nil
+}
+
+// addOptions returns the PostsAddOptions that bring the bookmark to
+// its desired state. Updates keep the time of the live bookmark;
+// otherwise Pinboard would move it to the top of the account.
+func (c Change) addOptions() *PostsAddOptions {
+	opt := &PostsAddOptions{
+		URL:         c.Desired.URL,
+		Description: c.Desired.Description,
+		Extended:    []byte(c.Desired.Extended),
+		Tags:        c.Desired.Tags,
+		Replace:     c.Action == ActionUpdate,
+		Shared:      c.Desired.Shared,
+		Toread:      c.Desired.Toread,
+	}
+
+	if c.Current != nil {
+		opt.Dt = c.Current.Time
+	}
+
+	return opt
+}
+
+// checkDrift fetches the live bookmark for c and makes sure it hasn't
+// changed since the plan was made.
+func checkDrift(c Change) error {
+	posts, err := PostsGet(&PostsGetOptions{URL: c.URL, Meta: true})
+	if err != nil {
+		return err
+	}
+
+	if len(posts) == 0 {
+		return fmt.Errorf("error: %s was deleted since the plan was made", c.URL)
+	}
+
+	if !bytes.Equal(posts[0].Meta, c.Current.Meta) {
+		return fmt.Errorf("error: %s changed since the plan was made", c.URL)
+	}
+
+	return nil
+}
@@ -0,0 +1,162 @@
+package pinboard
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestReadDesired(t *testing.T) {
+	desired, err := ReadDesired(strings.NewReader(`[
+		{"url": "https://golang.org/", "description": "Go", "tags": ["go"]},
+		{"url": "https://pinboard.in/", "description": "Pinboard", "shared": true}
+	]`))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(desired) != 2 {
+		t.Errorf("error: got %v, expected 2 desired posts", len(desired))
+	}
+
+	_, err = ReadDesired(strings.NewReader(`[{"url": "https://golang.org/"}]`))
+	if err == nil {
+		t.Error("error: expected missing description error")
+	}
+
+	_, err = ReadDesired(strings.NewReader(`[
+		{"url": "https://golang.org/", "description": "Go"},
+		{"url": "https://golang.org/", "description": "Go"}
+	]`))
+	if err == nil {
+		t.Error("error: expected duplicate url error")
+	}
+}
+
+func TestPlanPosts(t *testing.T) {
+	desired := []DesiredPost{
+		{URL: "https://golang.org/", Description: "Go", Tags: []string{"lang", "go"}},
+		{URL: "https://pinboard.in/", Description: "Pinboard", Tags: []string{"bookmarks"}},
+		{URL: "https://example.com/", Description: "Example"},
+	}
+
+	current := []*Post{
+		testPost("https://golang.org/", "Go", "go", "lang"),
+		testPost("https://pinboard.in/", "Pinboard", "old"),
+		testPost("https://unrelated.com/", "Unrelated"),
+	}
+
+	plan := PlanPosts(desired, current, false)
+	if len(plan.Changes) != 2 {
+		t.Fatalf("error: got %v, expected 2 changes", len(plan.Changes))
+	}
+
+	if plan.Changes[0].Action != ActionAdd || plan.Changes[0].URL != "https://example.com/" {
+		t.Errorf("error: expected add of example.com, got %v", plan.Changes[0])
+	}
+
+	if plan.Changes[1].Action != ActionUpdate || plan.Changes[1].Fields[0] != "tags" {
+		t.Errorf("error: expected tags update of pinboard.in, got %v", plan.Changes[1])
+	}
+
+	plan = PlanPosts(desired, current, true)
+	if len(plan.Changes) != 3 {
+		t.Fatalf("error: got %v, expected 3 changes", len(plan.Changes))
+	}
+
+	if plan.Changes[2].Action != ActionDelete || plan.Changes[2].URL != "https://unrelated.com/" {
+		t.Errorf("error: expected delete of unrelated.com, got %v", plan.Changes[2])
+	}
+
+	expected := "1 to add, 1 to update, 1 to delete"
+	if !strings.Contains(plan.String(), expected) {
+		t.Errorf("error: expected plan summary %q in %q", expected, plan.String())
+	}
+}
+
+func TestReadDesiredYAML(t *testing.T) {
+	desired, err := ReadDesiredYAML(strings.NewReader(`# Team bookmarks
+---
+- url: https://golang.org/
+  description: "Go: the \"language\""  # double quoted
+  tags: [go, 'lang']
+  shared: true
+
+# Block sequences may be indented or not.
+-   url: https://pinboard.in/
+    description: 'It''s #1'
+    tags:
+    - bookmarks   # comment
+    - "social"
+    toread: false
+- url: https://example.com/#top
+  description: Example # not part of the title
+  tags:
+      - example
+  extended: A plain scalar, with: a colon
+- url: https://go.dev/
+  description: Go
+  tags: go lang
+`))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := []DesiredPost{
+		{URL: "https://golang.org/", Description: `Go: the "language"`, Tags: []string{"go", "lang"}, Shared: true},
+		{URL: "https://pinboard.in/", Description: "It's #1", Tags: []string{"bookmarks", "social"}},
+		{URL: "https://example.com/#top", Description: "Example", Extended: "A plain scalar, with: a colon", Tags: []string{"example"}},
+		{URL: "https://go.dev/", Description: "Go", Tags: []string{"go", "lang"}},
+	}
+
+	if len(desired) != len(expected) {
+		t.Fatalf("error: got %v desired posts, expected %v", len(desired), len(expected))
+	}
+
+	for i, d := range desired {
+		if !reflect.DeepEqual(d, expected[i]) {
+			t.Errorf("error: got %+v, expected %+v", d, expected[i])
+		}
+	}
+
+	for _, invalid := range []string{
+		"- url: https://golang.org/\n",
+		"url: https://golang.org/\ndescription: Go\n",
+		"- url: https://golang.org/\n  description: \"Go\n",
+		"- url: https://golang.org/\n  description: Go\n  extended: |\n    Multi-line\n",
+		"- url: https://golang.org/\n  description: Go\n  shared: sometimes\n",
+		"- url: https://golang.org/\n  description: [Go]\n",
+	} {
+		_, err = ReadDesiredYAML(strings.NewReader(invalid))
+		if err == nil {
+			t.Errorf("error: expected error for %q", invalid)
+		}
+	}
+}
+
+func TestChangeAddOptions(t *testing.T) {
+	current := testPost("https://golang.org/", "Go", "go")
+	current.Shared = true
+
+	c := Change{
+		Action:  ActionUpdate,
+		URL:     "https://golang.org/",
+		Desired: &DesiredPost{URL: "https://golang.org/", Description: "The Go language", Tags: []string{"go", "lang"}},
+		Current: current,
+	}
+
+	opt := c.addOptions()
+	if !opt.Dt.Equal(current.Time) {
+		t.Errorf("error: got time %v, expected %v", opt.Dt, current.Time)
+	}
+
+	if !opt.Replace || opt.Description != "The Go language" || len(opt.Tags) != 2 || opt.Shared {
+		t.Errorf("error: expected desired fields, got %+v", opt)
+	}
+
+	c = Change{Action: ActionAdd, URL: c.URL, Desired: c.Desired}
+	opt = c.addOptions()
+	if !opt.Dt.IsZero() || opt.Replace {
+		t.Errorf("error: expected new bookmark options, got %+v", opt)
+	}
+}
@@ -47,6 +47,10 @@ type Post struct {
 	// The number of other users who have bookmarked this same
 	// item.
 	Others int
+
+	// User who saved the bookmark. Only set for bookmarks read from
+	// feeds, which may hold other users' bookmarks.
+	Author string
 }
 
 // post represents intermediate post response data before type
@@ -103,6 +107,59 @@ func (p *post) toPost() (*Post, error) {
 	return &P, nil
 }
 
+// PostJSON is the JSON representation of a Post used when sending
+// bookmarks to other tools, such as in webhook payloads.
+type PostJSON struct {
+	Href        string    `json:"href"`
+	Description string    `json:"description"`
+	Extended    string    `json:"extended"`
+	Tags        []string  `json:"tags"`
+	Shared      bool      `json:"shared"`
+	Toread      bool      `json:"toread"`
+	Time        time.Time `json:"time"`
+	Hash        string    `json:"hash"`
+	Meta        string    `json:"meta"`
+	Others      int       `json:"others"`
+}
+
+// toJSON converts the post to its JSON representation.
+func (p *Post) toJSON() *PostJSON {
+	return &PostJSON{
+		Href:        p.Href.String(),
+		Description: p.Description,
+		Extended:    string(p.Extended),
+		Tags:        cleanTags(p.Tags),
+		Shared:      p.Shared,
+		Toread:      p.Toread,
+		Time:        p.Time,
+		Hash:        string(p.Hash),
+		Meta:        string(p.Meta),
+		Others:      p.Others,
+	}
+}
+
+// addOptions returns the PostsAddOptions that recreate the post,
+// keeping its original time and privacy flags. Pinboard requires a
+// title, so an untitled post is given its URL as one.
+func (p *Post) addOptions() *PostsAddOptions {
+	opt := &PostsAddOptions{
+		URL:         p.Href.String(),
+		Description: p.Description,
+		Extended:    p.Extended,
+		Tags:        cleanTags(p.Tags),
+		Dt:          p.Time,
+		Replace:     true,
+		Shared:      p.Shared,
+		Toread:      p.Toread,
+	}
+
+	if opt.Description == "" {
+		opt.Description = opt.URL
+	}
+
+	return opt
+}
+
 // postsResponse represents a response from certain /posts/ endpoints.
 type postsResponse struct {
 	UpdateTime string `json:"update_time,omitempty"`
@@ -196,7 +253,7 @@ func PostsAdd(opt *PostsAddOptions) error {
 	}
 
 	if pr.ResultCode != "done" {
-		return errors.New(pr.ResultCode)
+		return &ResultError{Result: pr.ResultCode}
 	}
 
 	return nil
@@ -224,7 +281,7 @@ func PostsDelete(url string) error {
 	}
 
 	if pr.ResultCode != "done" {
-		return errors.New(pr.ResultCode)
+		return &ResultError{Result: pr.ResultCode}
 	}
 
 	return nil
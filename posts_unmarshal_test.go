@@ -36,3 +36,16 @@ func TestPostsUnmarshallingOkDescription(t *testing.T) {
 		t.Error("Wrong hash")
 	}
 }
+
+func TestPostAddOptions(t *testing.T) {
+	p := testPost("https://golang.org/", "", "go")
+
+	opt := p.addOptions()
+	if opt.Description != "https://golang.org/" {
+		t.Errorf("error: got %q, expected untitled post to be titled with its url", opt.Description)
+	}
+
+	if !opt.Dt.Equal(p.Time) || !opt.Replace || len(opt.Tags) != 1 {
+		t.Errorf("error: unexpected options %+v", opt)
+	}
+}
@@ -0,0 +1,254 @@
+package pinboard
+
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
+// QueryError is returned by ParseQuery for invalid queries. Pos is the
+// byte offset in the query where the problem was found.
+type QueryError struct {
+	Pos int
+	Msg string
+}
+
+func (e *QueryError) Error() string {
+	return fmt.Sprintf("error: query: %s at position %d", e.Msg, e.Pos)
+}
+
+// clause is a single term of a query.
+type clause struct {
+	neg   bool
+	match func(p *Post) bool
+}
+
+// Query is a compiled query that filters posts client-side. Every
+// clause of a query must match.
+//
+// The query language is a list of space separated terms:
+//
+//	word          word in the title, extended text, tags or URL
+//	"some words"  exact phrase in the title, extended text or URL
+//	tag:go        bookmark has the tag go
+//	site:go.dev   bookmark is on go.dev or one of its subdomains
+//	toread:yes    bookmark is (or with no, isn't) marked to read later
+//	shared:no     bookmark is private (or with yes, public)
+//	after:DATE    bookmark was saved on or after DATE (2006-01-02)
+//	before:DATE   bookmark was saved before DATE
+//
+// Prefixing a term with a minus negates it, as in -tag:archived.
+// Matching is case insensitive.
+type Query struct {
+	clauses []clause
+
+	tags   []string
+	after  time.Time
+	before time.Time
+}
+
+// ParseQuery compiles a query.
+func ParseQuery(q string) (*Query, error) {
+	var query Query
+
+	i := 0
+	for {
+		for i < len(q) && isSpace(q[i]) {
+			i++
+		}
+		if i >= len(q) {
+			break
+		}
+
+		start := i
+		neg := false
+		if q[i] == '-' {
+			neg = true
+			i++
+			if i >= len(q) || isSpace(q[i]) {
+				return nil, &QueryError{Pos: start, Msg: "expected term after -"}
+			}
+		}
+
+		// Quoted phrase.
+		if q[i] == '"' {
+			end := strings.IndexByte(q[i+1:], '"')
+			if end < 0 {
+				return nil, &QueryError{Pos: i, Msg: "unterminated quote"}
+			}
+			phrase := strings.ToLower(q[i+1 : i+1+end])
+			if phrase == "" {
+				return nil, &QueryError{Pos: i, Msg: "empty phrase"}
+			}
+			i += end + 2
+
+			query.clauses = append(query.clauses, clause{neg, func(p *Post) bool {
+				return strings.Contains(strings.ToLower(p.Description), phrase) ||
+					strings.Contains(strings.ToLower(string(p.Extended)), phrase) ||
+					strings.Contains(strings.ToLower(p.Href.String()), phrase)
+			}})
+			continue
+		}
+
+		termStart := i
+		for i < len(q) && !isSpace(q[i]) {
+			i++
+		}
+		term := q[termStart:i]
+
+		colon := strings.IndexByte(term, ':')
+		if colon < 0 || strings.Contains(term, "://") {
+			word := strings.ToLower(term)
+			query.clauses = append(query.clauses, clause{neg, func(p *Post) bool {
+				if hasTagFold(p.Tags, word) {
+					return true
+				}
+				return strings.Contains(strings.ToLower(p.Description), word) ||
+					strings.Contains(strings.ToLower(string(p.Extended)), word) ||
+					strings.Contains(strings.ToLower(p.Href.String()), word)
+			}})
+			continue
+		}
+
+		key, value := strings.ToLower(term[:colon]), term[colon+1:]
+		valuePos := termStart + colon + 1
+		if value == "" {
+			return nil, &QueryError{Pos: valuePos, Msg: "missing value for " + key}
+		}
+
+		c, err := query.field(key, value, neg)
+		if err != nil {
+			if qe, ok := err.(*QueryError); ok {
+				qe.Pos = valuePos
+				if qe.Msg == "unknown field" {
+					qe.Pos = termStart
+					qe.Msg = "unknown field " + key
+				}
+			}
+			return nil, err
+		}
+		query.clauses = append(query.clauses, c)
+	}
+
+	return &query, nil
+}
+
+// field compiles a key:value term. Error positions are filled in by
+// the caller.
+func (query *Query) field(key, value string, neg bool) (clause, error) {
+	switch key {
+	case "tag":
+		tag := strings.ToLower(value)
+		if !neg {
+			query.tags = append(query.tags, value)
+		}
+		return clause{neg, func(p *Post) bool {
+			return hasTagFold(p.Tags, tag)
+		}}, nil
+
+	case "site":
+		return clause{neg, func(p *Post) bool {
+			return onSite(p.Href.Hostname(), value)
+		}}, nil
+
+	case "toread", "shared":
+		var want bool
+		switch strings.ToLower(value) {
+		case "yes", "true":
+			want = true
+		case "no", "false":
+		default:
+			return clause{}, &QueryError{Msg: "expected yes or no"}
+		}
+		if key == "toread" {
+			return clause{neg, func(p *Post) bool { return p.Toread == want }}, nil
+		}
+		return clause{neg, func(p *Post) bool { return p.Shared == want }}, nil
+
+	case "after", "before":
+		dt, err := time.Parse("2006-01-02", value)
+		if err != nil {
+			return clause{}, &QueryError{Msg: "expected date as YYYY-MM-DD"}
+		}
+		if key == "after" {
+			if !neg {
+				query.after = dt
+			}
+			return clause{neg, func(p *Post) bool { return !p.Time.Before(dt) }}, nil
+		}
+		if !neg {
+			query.before = dt
+		}
+		return clause{neg, func(p *Post) bool { return p.Time.Before(dt) }}, nil
+	}
+
+	return clause{}, &QueryError{Msg: "unknown field"}
+}
+
+// isSpace reports whether c separates query terms.
+func isSpace(c byte) bool {
+	return c == ' ' || c == '\t' || c == '\n' || c == '\r'
+}
+
+// onSite reports whether host is site or one of its subdomains,
+// ignoring case and a leading "www.".
+func onSite(host, site string) bool {
+	host = strings.TrimPrefix(strings.ToLower(host), "www.")
+	site = strings.TrimPrefix(strings.ToLower(site), "www.")
+
+	return host == site || strings.HasSuffix(host, "."+site)
+}
+
+// hasTagFold reports whether tags contains tag, ignoring case.
+func hasTagFold(tags []string, tag string) bool {
+	for _, t := range tags {
+		if strings.EqualFold(t, tag) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// Match reports whether p matches every clause of the query.
+func (query *Query) Match(p *Post) bool {
+	for _, c := range query.clauses {
+		if c.match(p) == c.neg {
+			return false
+		}
+	}
+
+	return true
+}
+
+// Filter returns the posts that match the query.
+func (query *Query) Filter(posts []*Post) []*Post {
+	var matches []*Post
+	for _, p := range posts {
+		if query.Match(p) {
+			matches = append(matches, p)
+		}
+	}
+
+	return matches
+}
+
+// PostsAllOptions returns options for PostsAll that let the API do as
+// much of the filtering as it can: up to three of the query's tags
+// and its date range. The results still need to be passed through
+// Filter.
+func (query *Query) PostsAllOptions() *PostsAllOptions {
+	opt := &PostsAllOptions{
+		Fromdt: query.after,
+		Todt:   query.before,
+	}
+
+	for _, tag := range query.tags {
+		if len(opt.Tag) == 3 {
+			break
+		}
+		opt.Tag = append(opt.Tag, tag)
+	}
+
+	return opt
+}
@@ -0,0 +1,90 @@
+package pinboard
+
+import (
+	"testing"
+	"time"
+)
+
+func TestParseQuery(t *testing.T) {
+	pinboard := testPost("https://github.com/imwally/pinboard", "Pinboard Go Package", "go", "pinboard")
+	pinboard.Extended = []byte("A golang wrapper for the pinboard api.")
+	pinboard.Toread = true
+
+	archived := testPost("https://www.github.com/golang/go", "The Go programming language", "go", "archived")
+	archived.Shared = true
+	archived.Time = time.Date(2023, 5, 1, 0, 0, 0, 0, time.UTC)
+
+	posts := []*Post{pinboard, archived}
+
+	tests := []struct {
+		query   string
+		matches int
+	}{
+		{"tag:go", 2},
+		{"tag:GO -tag:archived", 1},
+		{"site:github.com", 2},
+		{"toread:yes shared:no", 1},
+		{"after:2023-01-01", 1},
+		{"before:2023-01-01", 1},
+		{`"golang wrapper"`, 1},
+		{`-"golang wrapper"`, 1},
+		{"programming", 1},
+		{"https://www.github.com/golang/go", 1},
+	}
+
+	for _, test := range tests {
+		q, err := ParseQuery(test.query)
+		if err != nil {
+			t.Errorf("error: %s: %s", test.query, err)
+			continue
+		}
+
+		got := len(q.Filter(posts))
+		if got != test.matches {
+			t.Errorf("error: %s: got %v, expected %v matches", test.query, got, test.matches)
+		}
+	}
+}
+
+func TestParseQueryErrors(t *testing.T) {
+	tests := []struct {
+		query string
+		pos   int
+	}{
+		{`tag:go "unterminated`, 7},
+		{"tag:go toread:maybe", 14},
+		{"colour:red", 0},
+		{"after:yesterday", 6},
+		{"tag:", 4},
+		{"go - tag:go", 3},
+	}
+
+	for _, test := range tests {
+		_, err := ParseQuery(test.query)
+		qe, ok := err.(*QueryError)
+		if !ok {
+			t.Errorf("error: %s: expected QueryError, got %v", test.query, err)
+			continue
+		}
+
+		if qe.Pos != test.pos {
+			t.Errorf("error: %s: got position %v, expected %v", test.query, qe.Pos, test.pos)
+		}
+	}
+}
+
+func TestQueryPostsAllOptions(t *testing.T) {
+	q, err := ParseQuery("tag:a tag:b -tag:c tag:d tag:e after:2023-01-01")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	opt := q.PostsAllOptions()
+	if len(opt.Tag) != 3 || opt.Tag[2] != "d" {
+		t.Errorf("error: got tags %v, expected [a b d]", opt.Tag)
+	}
+
+	if opt.Fromdt.Year() != 2023 || !opt.Todt.IsZero() {
+		t.Errorf("error: unexpected date range %v %v", opt.Fromdt, opt.Todt)
+	}
+}
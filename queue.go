@@ -0,0 +1,391 @@
+package pinboard
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"sort"
+	"time"
+)
+
+// QueueOrder is the order Queue.List returns bookmarks in.
+type QueueOrder int
+
+const (
+	// OldestFirst sorts by the time the bookmark was saved.
+	OldestFirst QueueOrder = iota
+
+	// NewestFirst sorts by the time the bookmark was saved, most
+	// recent first.
+	NewestFirst
+
+	// ShortestFirst sorts by the word count of the stored article.
+	// Bookmarks without a stored article come last. It requires
+	// Queue.ReadingList.
+	ShortestFirst
+)
+
+// QueueSize is the size of the read later queue at a point in time.
+type QueueSize struct {
+	Time time.Time `json:"time"`
+
+	// Number of unread bookmarks, snoozed ones included.
+	Unread int `json:"unread"`
+
+	// Number of unread bookmarks that are snoozed.
+	Snoozed int `json:"snoozed"`
+}
+
+// QueueState is the part of the queue kept locally, since Pinboard
+// only stores the toread flag.
+type QueueState struct {
+	// Time each snoozed bookmark is hidden until, keyed by URL.
+	Snoozed map[string]time.Time `json:"snoozed"`
+
+	// Size of the queue, at most one entry per day.
+	History []QueueSize `json:"history"`
+}
+
+// Queue manages the bookmarks marked to read later.
+type Queue struct {
+	// Time between API calls when changing several bookmarks.
+	// Defaults to RateLimit.
+	Interval time.Duration
+
+	// Reading list used to sort by article length. Optional.
+	ReadingList *ReadingList
+
+	// File the state is saved to. If empty, state is kept in
+	// memory only.
+	StatePath string
+
+	state QueueState
+}
+
+// OpenQueue returns a Queue that saves its state to statePath,
+// loading any state saved by a previous run.
+func OpenQueue(statePath string) (*Queue, error) {
+	q := &Queue{StatePath: statePath}
+
+	if statePath != "" {
+		data, err := ioutil.ReadFile(statePath)
+		if err != nil && !os.IsNotExist(err) {
+			return nil, err
+		}
+
+		if len(data) > 0 {
+			err = json.Unmarshal(data, &q.state)
+			if err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	if q.state.Snoozed == nil {
+		q.state.Snoozed = make(map[string]time.Time)
+	}
+
+	return q, nil
+}
+
+// State returns the locally kept state of the queue.
+func (q *Queue) State() QueueState {
+	return q.state
+}
+
+// History returns the size of the queue over time, oldest first.
+func (q *Queue) History() []QueueSize {
+	return q.state.History
+}
+
+// save writes the state to StatePath.
+func (q *Queue) save() error {
+	if q.StatePath == "" {
+		return nil
+	}
+
+	data, err := json.Marshal(q.state)
+	if err != nil {
+		return err
+	}
+
+	return writeFileAtomic(q.StatePath, data, 0600)
+}
+
+// Unread returns every bookmark marked to read later, snoozed ones
+// included, and records the size of the queue.
+func (q *Queue) Unread() ([]*Post, error) {
+	posts, err := PostsAll(nil)
+	if err != nil {
+		return nil, err
+	}
+
+	unread := q.record(posts, time.Now())
+
+	return unread, q.save()
+}
+
+// record returns the unread posts, forgets snoozes that are over or
+// belong to bookmarks no longer in the queue, and adds the size of
+// the queue to the history.
+func (q *Queue) record(posts []*Post, now time.Time) []*Post {
+	var unread []*Post
+	inQueue := make(map[string]bool)
+	for _, p := range posts {
+		if p.Toread {
+			unread = append(unread, p)
+			inQueue[p.Href.String()] = true
+		}
+	}
+
+	for u, until := range q.state.Snoozed {
+		if !inQueue[u] || !now.Before(until) {
+			delete(q.state.Snoozed, u)
+		}
+	}
+
+	size := QueueSize{
+		Time:    now.UTC(),
+		Unread:  len(unread),
+		Snoozed: len(q.state.Snoozed),
+	}
+
+	h := q.state.History
+	if n := len(h); n > 0 && h[n-1].Time.Format("2006-01-02") == size.Time.Format("2006-01-02") {
+		h[n-1] = size
+	} else {
+		q.state.History = append(h, size)
+	}
+
+	return unread
+}
+
+// QueueListOptions represents the optional arguments for listing the
+// queue.
+type QueueListOptions struct {
+	// Order of the results. Defaults to OldestFirst.
+	Order QueueOrder
+
+	// Only list bookmarks with this tag.
+	Tag string
+
+	// Include snoozed bookmarks.
+	Snoozed bool
+}
+
+// List returns the unread bookmarks that aren't snoozed.
+func (q *Queue) List(opt *QueueListOptions) ([]*Post, error) {
+	unread, err := q.Unread()
+	if err != nil {
+		return nil, err
+	}
+
+	return q.arrange(unread, opt, time.Now())
+}
+
+// arrange filters and sorts the unread posts.
+func (q *Queue) arrange(unread []*Post, opt *QueueListOptions, now time.Time) ([]*Post, error) {
+	if opt == nil {
+		opt = &QueueListOptions{}
+	}
+
+	var posts []*Post
+	for _, p := range unread {
+		if opt.Tag != "" && !hasTagFold(p.Tags, opt.Tag) {
+			continue
+		}
+
+		if until, ok := q.state.Snoozed[p.Href.String()]; ok && !opt.Snoozed && now.Before(until) {
+			continue
+		}
+
+		posts = append(posts, p)
+	}
+
+	sort.SliceStable(posts, func(i, j int) bool {
+		return posts[i].Time.Before(posts[j].Time)
+	})
+
+	switch opt.Order {
+	case OldestFirst:
+	case NewestFirst:
+		sort.SliceStable(posts, func(i, j int) bool {
+			return posts[i].Time.After(posts[j].Time)
+		})
+	case ShortestFirst:
+		if q.ReadingList == nil {
+			return nil, errors.New("error: sorting by length requires a reading list")
+		}
+
+		words := make(map[*Post]int)
+		for _, p := range posts {
+			a, err := q.ReadingList.Get(p)
+			if err != nil {
+				return nil, err
+			}
+
+			if a != nil {
+				words[p] = a.WordCount
+			}
+		}
+
+		sort.SliceStable(posts, func(i, j int) bool {
+			wi, iok := words[posts[i]]
+			wj, jok := words[posts[j]]
+			if iok != jok {
+				return iok
+			}
+			return wi < wj
+		})
+	default:
+		return nil, fmt.Errorf("error: unknown queue order %d", opt.Order)
+	}
+
+	return posts, nil
+}
+
+// MarkRead marks bookmarks as read. Each bookmark is fetched first so
+// that the rest of it is kept as is. It stops at the first error.
+func (q *Queue) MarkRead(urls ...string) error {
+	interval := q.Interval
+	if interval == 0 {
+		interval = RateLimit
+	}
+	pc := &pacer{interval: interval}
+
+	for _, u := range urls {
+		pc.wait()
+		posts, err := PostsGet(&PostsGetOptions{URL: u})
+		if err != nil {
+			return fmt.Errorf("error: %s: %s", u, err)
+		}
+
+		if len(posts) == 0 {
+			return fmt.Errorf("error: %s: item not found", u)
+		}
+
+		opt := posts[0].addOptions()
+		opt.Toread = false
+
+		pc.wait()
+		err = PostsAdd(opt)
+		if err != nil {
+			return fmt.Errorf("error: %s: %s", u, err)
+		}
+
+		delete(q.state.Snoozed, u)
+		err = q.save()
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// Snooze hides a bookmark from List until the given time.
+func (q *Queue) Snooze(url string, until time.Time) error {
+	if url == "" {
+		return errors.New("error: missing url")
+	}
+
+	q.state.Snoozed[url] = until.UTC()
+
+	return q.save()
+}
+
+// Unsnooze shows a snoozed bookmark in List again.
+func (q *Queue) Unsnooze(url string) error {
+	delete(q.state.Snoozed, url)
+
+	return q.save()
+}
+
+// ExpireOptions represents the arguments for expiring bookmarks that
+// stayed unread too long.
+type ExpireOptions struct {
+	// Required: bookmarks saved longer ago than this expire.
+	TTL time.Duration
+
+	// Tag added to expired bookmarks, which are then marked as
+	// read. Defaults to "expired".
+	Tag string
+
+	// Delete expired bookmarks instead of tagging them.
+	Delete bool
+
+	// Return the expired bookmarks without changing them.
+	DryRun bool
+}
+
+// Expire takes bookmarks that have been unread for longer than the
+// TTL out of the queue, either by tagging them and marking them as
+// read or by deleting them, and returns them. Snoozed bookmarks don't
+// expire.
+func (q *Queue) Expire(opt *ExpireOptions) ([]*Post, error) {
+	if opt == nil || opt.TTL <= 0 {
+		return nil, errors.New("error: missing ttl")
+	}
+
+	unread, err := q.Unread()
+	if err != nil {
+		return nil, err
+	}
+
+	stale := q.expired(unread, opt.TTL, time.Now())
+	if opt.DryRun {
+		return stale, nil
+	}
+
+	tag := firstNonEmpty(opt.Tag, "expired")
+
+	interval := q.Interval
+	if interval == 0 {
+		interval = RateLimit
+	}
+	pc := &pacer{interval: interval}
+
+	for i, p := range stale {
+		pc.wait()
+		if opt.Delete {
+			err = PostsDelete(p.Href.String())
+		} else {
+			add := p.addOptions()
+			add.Toread = false
+			add.Tags = unionTags(add.Tags, []string{tag})
+			err = PostsAdd(add)
+		}
+		if err != nil {
+			return stale[:i], fmt.Errorf("error: %s: %s", p.Href, err)
+		}
+	}
+
+	return stale, nil
+}
+
+// expired returns the unread posts saved longer than ttl before now,
+// oldest first, leaving out snoozed ones.
+func (q *Queue) expired(unread []*Post, ttl time.Duration, now time.Time) []*Post {
+	cutoff := now.Add(-ttl)
+
+	var stale []*Post
+	for _, p := range unread {
+		if !p.Toread || !p.Time.Before(cutoff) {
+			continue
+		}
+
+		if until, ok := q.state.Snoozed[p.Href.String()]; ok && now.Before(until) {
+			continue
+		}
+
+		stale = append(stale, p)
+	}
+
+	sort.SliceStable(stale, func(i, j int) bool {
+		return stale[i].Time.Before(stale[j].Time)
+	})
+
+	return stale
+}
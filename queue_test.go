@@ -0,0 +1,103 @@
+package pinboard
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestQueue(t *testing.T) {
+	dir, err := ioutil.TempDir("", "queue")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	now := time.Date(2011, 1, 20, 12, 0, 0, 0, time.UTC)
+
+	old := testPost("https://example.com/old", "Old", "go")
+	old.Toread = true
+	old.Time = now.AddDate(0, 0, -40)
+	middle := testPost("https://example.com/middle", "Middle")
+	middle.Toread = true
+	middle.Time = now.AddDate(0, 0, -10)
+	recent := testPost("https://example.com/recent", "Recent", "go")
+	recent.Toread = true
+	recent.Time = now.AddDate(0, 0, -1)
+	read := testPost("https://example.com/read", "Read")
+
+	rl := &ReadingList{Dir: dir}
+	for hash, words := range map[string]int{postHash(old): 500, postHash(recent): 100} {
+		data, _ := json.Marshal(&Article{Hash: hash, WordCount: words})
+		ioutil.WriteFile(filepath.Join(dir, hash+".json"), data, 0644)
+	}
+
+	statePath := filepath.Join(dir, "queue.json")
+	q, err := OpenQueue(statePath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	q.ReadingList = rl
+
+	err = q.Snooze(middle.Href.String(), now.AddDate(0, 0, 7))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	unread := q.record([]*Post{recent, read, old, middle}, now)
+	if len(unread) != 3 {
+		t.Fatalf("error: got %v, expected 3 unread posts", len(unread))
+	}
+
+	check := func(opt *QueueListOptions, expected ...*Post) {
+		t.Helper()
+
+		posts, err := q.arrange(unread, opt, now)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if len(posts) != len(expected) {
+			t.Fatalf("error: got %v posts, expected %v", len(posts), len(expected))
+		}
+
+		for i := range posts {
+			if posts[i] != expected[i] {
+				t.Errorf("error: got %v at %v, expected %v", posts[i].Href, i, expected[i].Href)
+			}
+		}
+	}
+
+	check(nil, old, recent)
+	check(&QueueListOptions{Order: NewestFirst}, recent, old)
+	check(&QueueListOptions{Order: ShortestFirst, Snoozed: true}, recent, old, middle)
+	check(&QueueListOptions{Tag: "GO", Order: NewestFirst}, recent, old)
+
+	stale := q.expired(unread, 7*24*time.Hour, now)
+	if len(stale) != 1 || stale[0] != old {
+		t.Errorf("error: got %v, expected only the old post to expire", stale)
+	}
+
+	// Snoozes are kept across runs and dropped once over.
+	q, err = OpenQueue(statePath)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, ok := q.State().Snoozed[middle.Href.String()]; !ok {
+		t.Error("error: expected snooze to be saved")
+	}
+
+	q.record(unread, now.AddDate(0, 0, 8))
+	if len(q.State().Snoozed) != 0 {
+		t.Errorf("error: expected snooze to be over, got %v", q.State().Snoozed)
+	}
+
+	history := q.History()
+	if len(history) != 1 || history[0].Unread != 3 || history[0].Snoozed != 0 {
+		t.Errorf("error: got history %+v, expected a single day with 3 unread", history)
+	}
+}
@@ -0,0 +1,497 @@
+package pinboard
+
+import (
+	"context"
+	"fmt"
+	"html"
+	"math"
+	"net/http"
+	"net/url"
+	"regexp"
+	"strings"
+	"time"
+	"unicode"
+)
+
+// WordsPerMinute is the reading speed used to estimate reading time.
+const WordsPerMinute = 200
+
+// Article is the main text of a web page with the clutter around it
+// removed.
+type Article struct {
+	// Hash of the bookmark the article belongs to, as in
+	// Post.Hash.
+	Hash string `json:"hash,omitempty"`
+
+	URL   string `json:"url"`
+	Title string `json:"title"`
+
+	// Plain text, with paragraphs separated by blank lines.
+	Text string `json:"text"`
+
+	// Simplified HTML holding only text level markup, links and
+	// images. Empty elements are self-closed so the markup is also
+	// valid XHTML.
+	HTML string `json:"html"`
+
+	WordCount int `json:"word_count"`
+
+	// Estimated reading time in whole minutes at WordsPerMinute.
+	ReadingMinutes int `json:"reading_minutes"`
+
+	Saved time.Time `json:"saved"`
+}
+
+// FetchArticle downloads rawurl and extracts its main text.
+func (f *Fetcher) FetchArticle(rawurl string) (*Article, error) {
+	p, err := f.get(context.Background(), rawurl)
+	if err != nil {
+		return nil, err
+	}
+
+	if p.status != http.StatusOK {
+		return nil, fmt.Errorf("error: %s: http %d", rawurl, p.status)
+	}
+
+	return ExtractArticle(p.url, p.body, p.contentType), nil
+}
+
+// ExtractArticle finds the main text of an HTML document fetched from
+// base using readability style heuristics: paragraphs are scored by
+// length and punctuation, scores flow up to their containers, and the
+// best scoring container with the fewest links wins.
+func ExtractArticle(base *url.URL, body []byte, contentType string) *Article {
+	root := parseHTMLTree(decodeHTML(body, contentType))
+
+	a := &Article{URL: base.String()}
+	if t := root.find("title"); t != nil {
+		a.Title = collapseSpace(t.text())
+	}
+	if m := ParseMetadata(base, body, contentType); m.BestTitle() != "" {
+		a.Title = m.BestTitle()
+	}
+
+	root.prune()
+
+	top := root.bestCandidate()
+	if top == nil {
+		top = root
+	}
+
+	r := &articleRenderer{base: base, lineStart: true}
+	r.render(top)
+
+	a.Text = collapseBlankLines(r.text.String())
+	a.HTML = strings.TrimSpace(r.markup.String())
+	a.WordCount = len(strings.Fields(a.Text))
+	a.ReadingMinutes = int(math.Ceil(float64(a.WordCount) / WordsPerMinute))
+
+	return a
+}
+
+// htmlNode is an element or text node of a parsed HTML document.
+type htmlNode struct {
+	tag      string
+	attrs    []htmlAttr
+	data     string
+	children []*htmlNode
+	parent   *htmlNode
+
+	score     float64
+	candidate bool
+}
+
+// voidElements never have children or end tags.
+var voidElements = map[string]bool{
+	"area": true, "base": true, "br": true, "col": true, "embed": true,
+	"hr": true, "img": true, "input": true, "link": true, "meta": true,
+	"param": true, "source": true, "track": true, "wbr": true,
+}
+
+// parseHTMLTree builds a tree from an HTML document. It recovers from
+// malformed markup by ignoring stray end tags and closing open
+// elements when an ancestor is closed.
+func parseHTMLTree(src string) *htmlNode {
+	root := &htmlNode{tag: "#root"}
+	cur := root
+
+	z := newHTMLTokenizer(src)
+	for {
+		t, ok := z.next()
+		if !ok {
+			return root
+		}
+
+		switch t.typ {
+		case textToken:
+			cur.children = append(cur.children, &htmlNode{data: t.data, parent: cur})
+
+		case startTagToken, selfClosingTagToken:
+			// A new paragraph or block closes an open paragraph.
+			if cur.tag == "p" && (t.data == "p" || blockElements[t.data]) {
+				cur = cur.parent
+			}
+
+			n := &htmlNode{tag: t.data, attrs: t.attrs, parent: cur}
+			cur.children = append(cur.children, n)
+			if t.typ == startTagToken && !voidElements[t.data] {
+				cur = n
+			}
+
+		case endTagToken:
+			for n := cur; n != root; n = n.parent {
+				if n.tag == t.data {
+					cur = n.parent
+					break
+				}
+			}
+		}
+	}
+}
+
+// blockElements are the elements that close an open <p>.
+var blockElements = map[string]bool{
+	"address": true, "article": true, "aside": true, "blockquote": true,
+	"div": true, "dl": true, "fieldset": true, "figure": true, "footer": true,
+	"form": true, "h1": true, "h2": true, "h3": true, "h4": true, "h5": true,
+	"h6": true, "header": true, "hr": true, "main": true, "nav": true,
+	"ol": true, "pre": true, "section": true, "table": true, "ul": true,
+}
+
+// attr returns the value of the attribute key.
+func (n *htmlNode) attr(key string) string {
+	for _, a := range n.attrs {
+		if a.key == key {
+			return a.val
+		}
+	}
+
+	return ""
+}
+
+// find returns the first element with the given tag.
+func (n *htmlNode) find(tag string) *htmlNode {
+	if n.tag == tag {
+		return n
+	}
+
+	for _, c := range n.children {
+		if f := c.find(tag); f != nil {
+			return f
+		}
+	}
+
+	return nil
+}
+
+// text returns the text content of the node.
+func (n *htmlNode) text() string {
+	if n.tag == "" {
+		return n.data
+	}
+
+	var b strings.Builder
+	for _, c := range n.children {
+		b.WriteString(c.text())
+	}
+
+	return b.String()
+}
+
+// linkText returns the length of the text inside links.
+func (n *htmlNode) linkText() int {
+	if n.tag == "a" {
+		return len(n.text())
+	}
+
+	var l int
+	for _, c := range n.children {
+		l += c.linkText()
+	}
+
+	return l
+}
+
+var (
+	// Elements that never hold article text.
+	junkElements = map[string]bool{
+		"script": true, "style": true, "noscript": true, "nav": true,
+		"header": true, "footer": true, "aside": true, "form": true,
+		"iframe": true, "svg": true, "button": true, "select": true,
+		"textarea": true, "head": true, "template": true,
+	}
+
+	unlikelyClass = regexp.MustCompile(`(?i)comment|sidebar|footer|masthead|menu|nav|share|social|sponsor|promo|related|subscribe|newsletter|popup|cookie|banner|\bads?\b`)
+	positiveClass = regexp.MustCompile(`(?i)article|body|content|entry|main|post|story|text|blog`)
+	negativeClass = regexp.MustCompile(`(?i)comment|meta|footer|sidebar|widget|hidden|byline|caption`)
+)
+
+// classWeight scores an element's class and id.
+func (n *htmlNode) classWeight() float64 {
+	var w float64
+	for _, s := range []string{n.attr("class"), n.attr("id")} {
+		if s == "" {
+			continue
+		}
+		if positiveClass.MatchString(s) {
+			w += 25
+		}
+		if negativeClass.MatchString(s) {
+			w -= 25
+		}
+	}
+
+	return w
+}
+
+// prune removes elements that are unlikely to be part of the article.
+func (n *htmlNode) prune() {
+	kept := n.children[:0]
+	for _, c := range n.children {
+		if junkElements[c.tag] {
+			continue
+		}
+
+		hint := c.attr("class") + " " + c.attr("id")
+		if c.tag != "body" && c.tag != "article" && c.tag != "main" &&
+			unlikelyClass.MatchString(hint) && !positiveClass.MatchString(hint) {
+			continue
+		}
+
+		c.prune()
+		kept = append(kept, c)
+	}
+	n.children = kept
+}
+
+// scoredElements are the elements whose text is scored.
+var scoredElements = map[string]bool{
+	"p": true, "pre": true, "td": true, "blockquote": true,
+}
+
+// bestCandidate scores paragraphs and returns the container with the
+// highest score, adjusted for link density.
+func (n *htmlNode) bestCandidate() *htmlNode {
+	var candidates []*htmlNode
+	n.walk(func(e *htmlNode) {
+		if !scoredElements[e.tag] {
+			return
+		}
+
+		text := collapseSpace(e.text())
+		if len(text) < 25 {
+			return
+		}
+
+		score := 1 + float64(strings.Count(text, ",")) + math.Min(float64(len(text))/100, 3)
+
+		for i, anc := 0, e.parent; i < 3 && anc != nil && anc.tag != "#root"; i, anc = i+1, anc.parent {
+			if !anc.candidate {
+				anc.candidate = true
+				anc.score = anc.classWeight()
+				switch anc.tag {
+				case "article", "main":
+					anc.score += 10
+				case "div", "section":
+					anc.score += 5
+				}
+				candidates = append(candidates, anc)
+			}
+			// Grandparents get half, great-grandparents a
+			// third.
+			anc.score += score / float64(i+1)
+		}
+	})
+
+	var best *htmlNode
+	var bestScore float64
+	for _, c := range candidates {
+		text := len(c.text())
+		if text == 0 {
+			continue
+		}
+
+		s := c.score * (1 - float64(c.linkText())/float64(text))
+		if best == nil || s > bestScore {
+			best, bestScore = c, s
+		}
+	}
+
+	return best
+}
+
+// walk calls fn for every element below n.
+func (n *htmlNode) walk(fn func(*htmlNode)) {
+	for _, c := range n.children {
+		if c.tag != "" {
+			fn(c)
+			c.walk(fn)
+		}
+	}
+}
+
+// blockTags are rendered as their own paragraphs.
+var blockTags = map[string]bool{
+	"p": true, "h1": true, "h2": true, "h3": true, "h4": true, "h5": true,
+	"h6": true, "pre": true, "blockquote": true, "li": true, "figcaption": true,
+}
+
+// inlineTags keep their markup in the rendered HTML.
+var inlineTags = map[string]bool{
+	"em": true, "strong": true, "b": true, "i": true, "code": true,
+	"sub": true, "sup": true,
+}
+
+// articleRenderer writes nodes as plain text and simplified HTML.
+type articleRenderer struct {
+	base   *url.URL
+	text   strings.Builder
+	markup strings.Builder
+
+	// Whether the plain text is at the start of a line.
+	lineStart bool
+}
+
+// writeText writes the text of a text node.
+func (r *articleRenderer) writeText(n *htmlNode) {
+	s := n.data
+	if !n.inPre() {
+		s = squashSpace(s)
+		if r.lineStart {
+			s = strings.TrimLeft(s, " ")
+		}
+	}
+	if s == "" {
+		return
+	}
+
+	r.text.WriteString(s)
+	r.markup.WriteString(html.EscapeString(s))
+	r.lineStart = strings.HasSuffix(s, "\n")
+}
+
+// newline ends the current line of plain text.
+func (r *articleRenderer) newline(s string) {
+	r.text.WriteString(s)
+	r.lineStart = true
+}
+
+// render writes n and its children.
+func (r *articleRenderer) render(n *htmlNode) {
+	if n.tag == "" {
+		r.writeText(n)
+		return
+	}
+
+	switch {
+	case n.tag == "br":
+		r.newline("\n")
+		r.markup.WriteString("<br/>")
+		return
+
+	case n.tag == "img":
+		src, err := r.base.Parse(strings.TrimSpace(n.attr("src")))
+		if err == nil && n.attr("src") != "" && safeScheme(src, "http", "https") {
+			fmt.Fprintf(&r.markup, `<img src="%s" alt="%s"/>`,
+				html.EscapeString(src.String()), html.EscapeString(n.attr("alt")))
+		}
+		return
+
+	case blockTags[n.tag] || n.tag == "ul" || n.tag == "ol":
+		r.newline("\n\n")
+		fmt.Fprintf(&r.markup, "<%s>", n.tag)
+		r.renderChildren(n)
+		fmt.Fprintf(&r.markup, "</%s>\n", n.tag)
+		r.newline("\n\n")
+		return
+
+	case n.tag == "a":
+		href, err := r.base.Parse(strings.TrimSpace(n.attr("href")))
+		if err != nil || n.attr("href") == "" || !safeScheme(href, "http", "https", "mailto") {
+			break
+		}
+		fmt.Fprintf(&r.markup, `<a href="%s">`, html.EscapeString(href.String()))
+		r.renderChildren(n)
+		r.markup.WriteString("</a>")
+		return
+
+	case inlineTags[n.tag]:
+		fmt.Fprintf(&r.markup, "<%s>", n.tag)
+		r.renderChildren(n)
+		fmt.Fprintf(&r.markup, "</%s>", n.tag)
+		return
+	}
+
+	r.renderChildren(n)
+}
+
+// renderChildren renders the children of n.
+func (r *articleRenderer) renderChildren(n *htmlNode) {
+	for _, c := range n.children {
+		r.render(c)
+	}
+}
+
+// inPre reports whether the node is inside a <pre>.
+func (n *htmlNode) inPre() bool {
+	for p := n.parent; p != nil; p = p.parent {
+		if p.tag == "pre" {
+			return true
+		}
+	}
+
+	return false
+}
+
+// squashSpace replaces runs of whitespace with a single space.
+func squashSpace(s string) string {
+	var b strings.Builder
+	space := false
+	for _, r := range s {
+		if unicode.IsSpace(r) {
+			if !space {
+				b.WriteByte(' ')
+			}
+			space = true
+			continue
+		}
+		b.WriteRune(r)
+		space = false
+	}
+
+	return b.String()
+}
+
+// collapseBlankLines trims trailing space from the lines of s and
+// replaces runs of blank lines with a single one.
+func collapseBlankLines(s string) string {
+	var lines []string
+	blank := false
+	for _, l := range strings.Split(s, "\n") {
+		l = strings.TrimRight(l, " \t")
+		if strings.TrimSpace(l) == "" {
+			blank = len(lines) > 0
+			continue
+		}
+
+		if blank {
+			lines = append(lines, "")
+			blank = false
+		}
+		lines = append(lines, l)
+	}
+
+	return strings.Join(lines, "\n")
+}
+
+// safeScheme reports whether u uses one of schemes, which keeps links
+// such as javascript: out of the article HTML.
+func safeScheme(u *url.URL, schemes ...string) bool {
+	for _, s := range schemes {
+		if strings.EqualFold(u.Scheme, s) {
+			return true
+		}
+	}
+
+	return false
+}
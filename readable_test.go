@@ -0,0 +1,75 @@
+package pinboard
+
+import (
+	"net/url"
+	"strings"
+	"testing"
+)
+
+const testArticleHTML = `<!DOCTYPE html>
+<html><head><title>Site | A Tale</title><meta property="og:title" content="A Tale"></head>
+<body>
+<nav><a href="/">Home</a> <a href="/about">About</a></nav>
+<div class="sidebar"><p>Subscribe to our newsletter, it is great, really, truly great.</p></div>
+<div id="main-content" class="post">
+<h1>A Tale</h1>
+<p>It was the best of times, it was the worst of times, it was the age of wisdom,
+it was the age of foolishness.
+<p>It was the epoch of belief, it was the epoch of incredulity, it was the season of
+<a href="/light">Light</a>, it was the season of <em>Darkness</em>.</p>
+<pre>  indented
+  code</pre>
+<script>var tracking = "it was, it was, it was, it was";</script>
+</div>
+<div class="comments"><p>First comment, nice, great, cool, wow, amazing, yes, indeed.</p></div>
+<footer><p>Copyright, all rights reserved, by the company, inc, ltd, etc.</p></footer>
+</body></html>`
+
+func TestExtractArticle(t *testing.T) {
+	base, _ := url.Parse("https://example.com/tale")
+
+	a := ExtractArticle(base, []byte(testArticleHTML), "text/html; charset=utf-8")
+
+	if a.Title != "A Tale" {
+		t.Errorf("error: got title %q", a.Title)
+	}
+
+	if !strings.HasPrefix(a.Text, "A Tale\n\nIt was the best of times") {
+		t.Errorf("error: unexpected text %q", a.Text)
+	}
+
+	for _, junk := range []string{"Home", "newsletter", "comment", "Copyright", "tracking"} {
+		if strings.Contains(a.Text, junk) {
+			t.Errorf("error: expected %q to be removed from %q", junk, a.Text)
+		}
+	}
+
+	if !strings.Contains(a.Text, "indented\n  code") {
+		t.Errorf("error: expected preformatted text to be kept in %q", a.Text)
+	}
+
+	if !strings.Contains(a.HTML, `<a href="https://example.com/light">Light</a>`) ||
+		!strings.Contains(a.HTML, "<em>Darkness</em>") {
+		t.Errorf("error: unexpected html %q", a.HTML)
+	}
+
+	if a.WordCount != 52 || a.ReadingMinutes != 1 {
+		t.Errorf("error: got %v words and %v minutes, expected 52 words and 1 minute", a.WordCount, a.ReadingMinutes)
+	}
+}
+
+func TestExtractArticleUnsafeLinks(t *testing.T) {
+	base, _ := url.Parse("https://example.com/tale")
+
+	src := strings.Replace(testArticleHTML, `<a href="/light">Light</a>`,
+		`<a href=" JavaScript:alert(1)">Light</a> <a href="mailto:dickens@example.com">Mail</a> <img src="vbscript:x">`, 1)
+	a := ExtractArticle(base, []byte(src), "text/html; charset=utf-8")
+
+	if strings.Contains(strings.ToLower(a.HTML), "script:") {
+		t.Errorf("error: expected script links to be removed from %q", a.HTML)
+	}
+
+	if !strings.Contains(a.HTML, "season of Light ") || !strings.Contains(a.HTML, `<a href="mailto:dickens@example.com">Mail</a>`) {
+		t.Errorf("error: expected link text and mailto link to be kept in %q", a.HTML)
+	}
+}
@@ -0,0 +1,153 @@
+package pinboard
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+// ReadingList is a local archive of the extracted text of read later
+// bookmarks, stored as one JSON file per article named after the
+// bookmark's Hash.
+type ReadingList struct {
+	// Fetcher used for downloads. Optional.
+	Fetcher *Fetcher
+
+	// Required: directory the articles are stored in.
+	Dir string
+}
+
+// path returns the file an article for the given hash is stored in.
+func (rl *ReadingList) path(hash string) string {
+	return filepath.Join(rl.Dir, hash+".json")
+}
+
+// Sync fetches and stores the article of every post marked to read
+// later that isn't stored yet. A page that fails to download doesn't
+// stop the others; the first such error is returned along with the
+// articles that were stored.
+func (rl *ReadingList) Sync(posts []*Post) ([]*Article, error) {
+	err := os.MkdirAll(rl.Dir, 0755)
+	if err != nil {
+		return nil, err
+	}
+
+	var stored []*Article
+	var firstErr error
+	for _, p := range posts {
+		if !p.Toread {
+			continue
+		}
+
+		_, err := os.Stat(rl.path(postHash(p)))
+		if err == nil {
+			continue
+		}
+
+		a, err := rl.Save(p)
+		if err != nil {
+			if firstErr == nil {
+				firstErr = fmt.Errorf("error: %s: %s", p.Href, err)
+			}
+			continue
+		}
+
+		stored = append(stored, a)
+	}
+
+	return stored, firstErr
+}
+
+// Save fetches the article for p and stores it, replacing any stored
+// copy.
+func (rl *ReadingList) Save(p *Post) (*Article, error) {
+	var f Fetcher
+	if rl.Fetcher != nil {
+		f = *rl.Fetcher
+	}
+
+	a, err := f.FetchArticle(p.Href.String())
+	if err != nil {
+		return nil, err
+	}
+
+	a.Hash = postHash(p)
+	a.URL = p.Href.String()
+	a.Saved = time.Now().UTC()
+	if p.Description != "" {
+		a.Title = p.Description
+	}
+
+	data, err := json.Marshal(a)
+	if err != nil {
+		return nil, err
+	}
+
+	err = writeFileAtomic(rl.path(a.Hash), data, 0644)
+	if err != nil {
+		return nil, err
+	}
+
+	return a, nil
+}
+
+// Get returns the stored article for p, or nil if there isn't one.
+func (rl *ReadingList) Get(p *Post) (*Article, error) {
+	return rl.load(rl.path(postHash(p)))
+}
+
+// load reads a stored article, returning nil if the file doesn't
+// exist.
+func (rl *ReadingList) load(path string) (*Article, error) {
+	data, err := ioutil.ReadFile(path)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var a Article
+	err = json.Unmarshal(data, &a)
+	if err != nil {
+		return nil, err
+	}
+
+	return &a, nil
+}
+
+// List returns every stored article, shortest first.
+func (rl *ReadingList) List() ([]*Article, error) {
+	files, err := ioutil.ReadDir(rl.Dir)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var articles []*Article
+	for _, f := range files {
+		if f.IsDir() || !strings.HasSuffix(f.Name(), ".json") {
+			continue
+		}
+
+		a, err := rl.load(filepath.Join(rl.Dir, f.Name()))
+		if err != nil {
+			return nil, err
+		}
+
+		articles = append(articles, a)
+	}
+
+	sort.SliceStable(articles, func(i, j int) bool {
+		return articles[i].WordCount < articles[j].WordCount
+	})
+
+	return articles, nil
+}
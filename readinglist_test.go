@@ -0,0 +1,72 @@
+package pinboard
+
+import (
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestReadingList(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		words := strings.Repeat("word, ", len(r.URL.Path)*20)
+		fmt.Fprintf(w, "<html><body><div><p>%s</p></div></body></html>", words)
+	}))
+	defer srv.Close()
+
+	dir, err := ioutil.TempDir("", "readinglist")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	long := testPost(srv.URL+"/long", "Long")
+	long.Toread = true
+	short := testPost(srv.URL+"/s", "Short")
+	short.Toread = true
+	read := testPost(srv.URL+"/read", "Read")
+
+	rl := &ReadingList{Dir: dir}
+
+	stored, err := rl.Sync([]*Post{long, short, read})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(stored) != 2 {
+		t.Fatalf("error: got %v, expected 2 stored articles", len(stored))
+	}
+
+	stored, err = rl.Sync([]*Post{long, short})
+	if err != nil || len(stored) != 0 {
+		t.Errorf("error: expected stored articles to be skipped, got %v %v", stored, err)
+	}
+
+	articles, err := rl.List()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(articles) != 2 || articles[0].Title != "Short" || articles[0].WordCount != 40 || articles[0].ReadingMinutes != 1 {
+		t.Errorf("error: expected shortest article first, got %+v", articles)
+	}
+
+	a, err := rl.Get(long)
+	if err != nil || a == nil || a.Hash != string(long.Hash) {
+		t.Errorf("error: expected stored article for %s, got %v %v", long.Href, a, err)
+	}
+
+	_, err = os.Stat(filepath.Join(dir, string(long.Hash)+".json"))
+	if err != nil {
+		t.Errorf("error: expected article to be stored under the bookmark hash: %v", err)
+	}
+
+	a, err = rl.Get(read)
+	if err != nil || a != nil {
+		t.Errorf("error: expected no article for %s, got %v %v", read.Href, a, err)
+	}
+}
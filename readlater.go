@@ -0,0 +1,312 @@
+package pinboard
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"io"
+	"io/ioutil"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// The importers below read the exports of read later services. They
+// are lenient: a field that can't be parsed is left empty rather than
+// failing the whole file, and entries missing a URL are passed on so
+// that Import reports them.
+
+// unixTime converts a Unix timestamp in seconds to a time. An empty or
+// invalid timestamp gives the zero time.
+func unixTime(s string) time.Time {
+	sec, err := strconv.ParseInt(strings.TrimSpace(s), 10, 64)
+	if err != nil || sec <= 0 {
+		return time.Time{}
+	}
+
+	return time.Unix(sec, 0).UTC()
+}
+
+// readCSV reads a CSV file with a header row and returns its records
+// as maps from lower cased column name to value.
+func readCSV(r io.Reader) ([]map[string]string, error) {
+	cr := csv.NewReader(r)
+	cr.FieldsPerRecord = -1
+	cr.LazyQuotes = true
+
+	header, err := cr.Read()
+	if err != nil {
+		return nil, err
+	}
+
+	for i, h := range header {
+		header[i] = strings.ToLower(strings.TrimSpace(strings.TrimPrefix(h, "\ufeff")))
+	}
+
+	var records []map[string]string
+	for {
+		record, err := cr.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		m := make(map[string]string)
+		for i, v := range record {
+			if i < len(header) {
+				m[header[i]] = v
+			}
+		}
+		records = append(records, m)
+	}
+
+	return records, nil
+}
+
+// splitTags splits a list of tags on sep, turning each into a valid
+// Pinboard tag.
+func splitTags(s, sep string) []string {
+	var tags []string
+	for _, t := range strings.Split(s, sep) {
+		if t = tagName(t); t != "" {
+			tags = append(tags, t)
+		}
+	}
+
+	return tags
+}
+
+// ParsePocketHTML reads Pocket's HTML export, which lists unread
+// bookmarks under an "Unread" heading and read ones under "Read
+// Archive".
+func ParsePocketHTML(r io.Reader) ([]*PostsAddOptions, error) {
+	body, err := ioutil.ReadAll(r)
+	if err != nil {
+		return nil, err
+	}
+
+	var items []*PostsAddOptions
+	var item *PostsAddOptions
+	var heading strings.Builder
+	var inHeading, toread bool
+
+	z := newHTMLTokenizer(decodeHTML(body, "text/html; charset=utf-8"))
+	for {
+		t, ok := z.next()
+		if !ok {
+			break
+		}
+
+		switch t.typ {
+		case startTagToken:
+			switch t.data {
+			case "h1":
+				inHeading = true
+				heading.Reset()
+			case "a":
+				item = &PostsAddOptions{
+					URL:    strings.TrimSpace(t.attr("href")),
+					Tags:   splitTags(t.attr("tags"), ","),
+					Dt:     unixTime(t.attr("time_added")),
+					Toread: toread,
+				}
+			}
+
+		case textToken:
+			if inHeading {
+				heading.WriteString(t.data)
+			}
+			if item != nil {
+				item.Description += t.data
+			}
+
+		case endTagToken:
+			switch t.data {
+			case "h1":
+				inHeading = false
+				toread = !strings.Contains(strings.ToLower(heading.String()), "read archive")
+			case "a":
+				if item != nil {
+					item.Description = firstNonEmpty(collapseSpace(item.Description), item.URL)
+					items = append(items, item)
+					item = nil
+				}
+			}
+		}
+	}
+
+	return items, nil
+}
+
+// ParsePocketCSV reads Pocket's CSV export, with the columns title,
+// url, time_added, tags and status. Tags are separated by "|" and the
+// status is "unread" or "archive".
+func ParsePocketCSV(r io.Reader) ([]*PostsAddOptions, error) {
+	records, err := readCSV(r)
+	if err != nil {
+		return nil, err
+	}
+
+	var items []*PostsAddOptions
+	for _, rec := range records {
+		url := strings.TrimSpace(rec["url"])
+		items = append(items, &PostsAddOptions{
+			URL:         url,
+			Description: firstNonEmpty(strings.TrimSpace(rec["title"]), url),
+			Tags:        splitTags(rec["tags"], "|"),
+			Dt:          unixTime(rec["time_added"]),
+			Toread:      strings.ToLower(strings.TrimSpace(rec["status"])) != "archive",
+		})
+	}
+
+	return items, nil
+}
+
+// ParseInstapaperCSV reads Instapaper's CSV export, with the columns
+// URL, Title, Selection, Folder, Timestamp and, in newer exports,
+// Tags. Bookmarks in the Unread folder are marked to read later,
+// Starred ones are tagged "starred" and other folders become tags.
+// The selection, the text highlighted when saving, becomes Extended.
+func ParseInstapaperCSV(r io.Reader) ([]*PostsAddOptions, error) {
+	records, err := readCSV(r)
+	if err != nil {
+		return nil, err
+	}
+
+	var items []*PostsAddOptions
+	for _, rec := range records {
+		url := strings.TrimSpace(rec["url"])
+		item := &PostsAddOptions{
+			URL:         url,
+			Description: firstNonEmpty(strings.TrimSpace(rec["title"]), url),
+			Extended:    []byte(strings.TrimSpace(rec["selection"])),
+			Dt:          unixTime(rec["timestamp"]),
+		}
+
+		switch folder := strings.TrimSpace(rec["folder"]); strings.ToLower(folder) {
+		case "unread":
+			item.Toread = true
+		case "archive", "":
+		case "starred":
+			item.Tags = []string{"starred"}
+		default:
+			item.Tags = []string{tagName(folder)}
+		}
+
+		// Tags are a JSON array of strings.
+		var tags []string
+		if json.Unmarshal([]byte(rec["tags"]), &tags) == nil {
+			for _, t := range tags {
+				item.Tags = unionTags(item.Tags, []string{tagName(t)})
+			}
+		}
+
+		items = append(items, item)
+	}
+
+	return items, nil
+}
+
+// flexBool is a boolean that may be written as true, false, 1 or 0.
+type flexBool bool
+
+func (b *flexBool) UnmarshalJSON(data []byte) error {
+	switch strings.Trim(string(data), `"`) {
+	case "true", "1":
+		*b = true
+	default:
+		*b = false
+	}
+
+	return nil
+}
+
+// wallabagTags are tags written either as strings or as objects with
+// a label.
+type wallabagTags []string
+
+func (t *wallabagTags) UnmarshalJSON(data []byte) error {
+	var names []string
+	if json.Unmarshal(data, &names) == nil {
+		*t = names
+		return nil
+	}
+
+	var labels []struct {
+		Label string `json:"label"`
+	}
+	err := json.Unmarshal(data, &labels)
+	if err != nil {
+		return err
+	}
+
+	for _, l := range labels {
+		*t = append(*t, l.Label)
+	}
+
+	return nil
+}
+
+type wallabagEntry struct {
+	URL         string       `json:"url"`
+	Title       string       `json:"title"`
+	IsArchived  flexBool     `json:"is_archived"`
+	IsStarred   flexBool     `json:"is_starred"`
+	Tags        wallabagTags `json:"tags"`
+	CreatedAt   string       `json:"created_at"`
+	Annotations []struct {
+		Quote string `json:"quote"`
+		Text  string `json:"text"`
+	} `json:"annotations"`
+}
+
+// ParseWallabag reads Wallabag's JSON export. Archived entries are
+// read, starred ones are tagged "starred", and annotations become
+// Extended, each highlighted quote followed by its note.
+func ParseWallabag(r io.Reader) ([]*PostsAddOptions, error) {
+	var entries []wallabagEntry
+	err := json.NewDecoder(r).Decode(&entries)
+	if err != nil {
+		return nil, err
+	}
+
+	var items []*PostsAddOptions
+	for _, e := range entries {
+		url := strings.TrimSpace(e.URL)
+		item := &PostsAddOptions{
+			URL:         url,
+			Description: firstNonEmpty(strings.TrimSpace(e.Title), url),
+			Toread:      !bool(e.IsArchived),
+		}
+
+		for _, t := range e.Tags {
+			item.Tags = unionTags(item.Tags, []string{tagName(t)})
+		}
+		if e.IsStarred {
+			item.Tags = unionTags(item.Tags, []string{"starred"})
+		}
+
+		// Wallabag writes offsets without a colon.
+		for _, layout := range []string{time.RFC3339, "2006-01-02T15:04:05-0700"} {
+			if dt, err := time.Parse(layout, e.CreatedAt); err == nil {
+				item.Dt = dt.UTC()
+				break
+			}
+		}
+
+		var notes []string
+		for _, a := range e.Annotations {
+			note := "> " + strings.TrimSpace(a.Quote)
+			if text := strings.TrimSpace(a.Text); text != "" {
+				note += "\n" + text
+			}
+			notes = append(notes, note)
+		}
+		item.Extended = []byte(strings.Join(notes, "\n\n"))
+
+		items = append(items, item)
+	}
+
+	return items, nil
+}
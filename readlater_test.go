@@ -0,0 +1,134 @@
+package pinboard
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+const testPocketHTML = `<!DOCTYPE html>
+<html>
+	<head>
+		<meta http-equiv="Content-Type" content="text/html; charset=UTF-8">
+		<title>Pocket Export</title>
+	</head>
+	<body>
+		<h1>Unread</h1>
+		<ul>
+			<li><a href="https://golang.org/" time_added="1292096882" tags="go,Programming Languages">The Go
+			Programming Language</a></li>
+		</ul>
+
+		<h1>Read Archive</h1>
+		<ul>
+			<li><a href="https://example.com/" time_added="1292000000" tags="">https://example.com/</a></li>
+		</ul>
+	</body>
+</html>`
+
+func TestParsePocketHTML(t *testing.T) {
+	items, err := ParsePocketHTML(strings.NewReader(testPocketHTML))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(items) != 2 {
+		t.Fatalf("error: got %v items, expected 2", len(items))
+	}
+
+	g := items[0]
+	if g.Description != "The Go Programming Language" || !g.Toread || g.Dt.Unix() != 1292096882 {
+		t.Errorf("error: got %+v", g)
+	}
+
+	if strings.Join(g.Tags, " ") != "go Programming-Languages" {
+		t.Errorf("error: got tags %v", g.Tags)
+	}
+
+	if items[1].Toread || len(items[1].Tags) != 0 {
+		t.Errorf("error: got %+v", items[1])
+	}
+}
+
+func TestParsePocketCSV(t *testing.T) {
+	items, err := ParsePocketCSV(strings.NewReader("title,url,time_added,cursor,tags,status\n" +
+		"Go,https://golang.org/,1292096882,1,go|lang,unread\n" +
+		",https://example.com/,1292000000,2,,archive\n"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(items) != 2 || !items[0].Toread || items[1].Toread {
+		t.Fatalf("error: got %+v", items)
+	}
+
+	if len(items[0].Tags) != 2 || items[1].Description != "https://example.com/" {
+		t.Errorf("error: got %+v and %+v", items[0], items[1])
+	}
+}
+
+func TestParseInstapaperCSV(t *testing.T) {
+	items, err := ParseInstapaperCSV(strings.NewReader("URL,Title,Selection,Folder,Timestamp,Tags\n" +
+		`https://golang.org/,Go,"Simple, reliable, efficient.",Unread,1292096882,"[""go""]"` + "\n" +
+		`https://example.com/,Example,,Starred,1292000000,[]` + "\n" +
+		`https://pinboard.in/,Pinboard,,Web Tools,1292000000,` + "\n" +
+		`https://archived.com/,Archived,,Archive,1292000000,` + "\n"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(items) != 4 {
+		t.Fatalf("error: got %v items, expected 4", len(items))
+	}
+
+	g := items[0]
+	if !g.Toread || string(g.Extended) != "Simple, reliable, efficient." || strings.Join(g.Tags, " ") != "go" {
+		t.Errorf("error: got %+v", g)
+	}
+
+	if items[1].Toread || strings.Join(items[1].Tags, " ") != "starred" {
+		t.Errorf("error: got %+v", items[1])
+	}
+
+	if strings.Join(items[2].Tags, " ") != "Web-Tools" {
+		t.Errorf("error: got %+v", items[2])
+	}
+
+	if items[3].Toread || len(items[3].Tags) != 0 {
+		t.Errorf("error: got %+v", items[3])
+	}
+}
+
+func TestParseWallabag(t *testing.T) {
+	items, err := ParseWallabag(strings.NewReader(`[
+		{"is_archived": 0, "is_starred": 1, "tags": ["go", "web dev"], "title": "Go",
+		 "url": "https://golang.org/", "created_at": "2010-12-11T20:48:02+0100",
+		 "annotations": [{"text": "Nice.", "quote": "Go is expressive"}, {"text": "", "quote": "concise"}]},
+		{"is_archived": true, "is_starred": false, "tags": [{"label": "misc"}], "title": "",
+		 "url": "https://example.com/", "created_at": "2010-12-10T08:00:00Z"}
+	]`))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(items) != 2 {
+		t.Fatalf("error: got %v items, expected 2", len(items))
+	}
+
+	g := items[0]
+	if !g.Toread || strings.Join(g.Tags, " ") != "go web-dev starred" {
+		t.Errorf("error: got %+v", g)
+	}
+
+	if !g.Dt.Equal(time.Date(2010, 12, 11, 19, 48, 2, 0, time.UTC)) {
+		t.Errorf("error: got %v", g.Dt)
+	}
+
+	if string(g.Extended) != "> Go is expressive\nNice.\n\n> concise" {
+		t.Errorf("error: got extended %q", g.Extended)
+	}
+
+	if items[1].Toread || items[1].Tags[0] != "misc" || items[1].Description != "https://example.com/" {
+		t.Errorf("error: got %+v", items[1])
+	}
+}
@@ -0,0 +1,245 @@
+package pinboard
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+// DefaultShorteners are hosts of common link shortening services.
+var DefaultShorteners = []string{
+	"t.co",
+	"bit.ly",
+	"bitly.com",
+	"buff.ly",
+	"dlvr.it",
+	"fb.me",
+	"goo.gl",
+	"ift.tt",
+	"is.gd",
+	"lnkd.in",
+	"ow.ly",
+	"tinyurl.com",
+	"trib.al",
+	"wp.me",
+}
+
+// Resolution is the result of following a URL's redirects.
+type Resolution struct {
+	// URL that was resolved.
+	URL string
+
+	// URL at the end of the redirects.
+	Final string
+
+	// Every URL visited after URL, ending with Final.
+	Hops []string
+}
+
+// Resolver follows redirects of shortened links to find the URL they
+// point to.
+type Resolver struct {
+	// Fetcher used for requests. Its Client's redirect policy is
+	// replaced so that each hop can be inspected.
+	Fetcher *Fetcher
+
+	// Maximum number of redirects to follow. Defaults to 10.
+	MaxHops int
+
+	// Hosts considered link shorteners. Defaults to
+	// DefaultShorteners.
+	Shorteners []string
+}
+
+// IsShort reports whether rawurl is on a link shortener.
+func (r *Resolver) IsShort(rawurl string) bool {
+	u, err := url.Parse(rawurl)
+	if err != nil {
+		return false
+	}
+
+	shorteners := r.Shorteners
+	if shorteners == nil {
+		shorteners = DefaultShorteners
+	}
+
+	for _, s := range shorteners {
+		if onSite(u.Hostname(), s) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// Resolve follows the redirects of rawurl, one hop at a time, until it
+// reaches a URL that doesn't redirect. Shorteners that answer with a
+// <meta> refresh page instead of a redirect, like t.co does for some
+// clients, are followed too. Redirect loops and chains longer than
+// MaxHops are errors.
+func (r *Resolver) Resolve(rawurl string) (*Resolution, error) {
+	maxHops := r.MaxHops
+	if maxHops == 0 {
+		maxHops = 10
+	}
+
+	var f Fetcher
+	if r.Fetcher != nil {
+		f = *r.Fetcher
+	}
+
+	client := http.DefaultClient
+	if f.Client != nil {
+		client = f.Client
+	}
+
+	noFollow := *client
+	noFollow.CheckRedirect = func(req *http.Request, via []*http.Request) error {
+		return http.ErrUseLastResponse
+	}
+
+	f.Client = &noFollow
+
+	res := &Resolution{URL: rawurl, Final: rawurl}
+	seen := map[string]bool{rawurl: true}
+
+	for {
+		u, err := url.Parse(res.Final)
+		if err != nil {
+			return nil, err
+		}
+
+		next, err := r.next(&f, u)
+		if err != nil {
+			return nil, err
+		}
+
+		if next == "" {
+			return res, nil
+		}
+
+		if seen[next] {
+			return nil, fmt.Errorf("error: redirect loop at %s", next)
+		}
+		seen[next] = true
+
+		if len(res.Hops) == maxHops {
+			return nil, fmt.Errorf("error: more than %d redirects from %s", maxHops, rawurl)
+		}
+
+		res.Hops = append(res.Hops, next)
+		res.Final = next
+	}
+}
+
+// next returns the URL u redirects to, or "" if it doesn't.
+func (r *Resolver) next(f *Fetcher, u *url.URL) (string, error) {
+	p, err := f.get(context.Background(), u.String())
+	if err != nil {
+		return "", err
+	}
+
+	if p.status >= 300 && p.status < 400 {
+		loc := p.location
+		if loc == "" {
+			return "", fmt.Errorf("error: %s: http %d without location", u, p.status)
+		}
+
+		next, err := u.Parse(loc)
+		if err != nil {
+			return "", err
+		}
+
+		return next.String(), nil
+	}
+
+	if p.status != http.StatusOK {
+		return "", fmt.Errorf("error: %s: http %d", u, p.status)
+	}
+
+	if !r.IsShort(u.String()) {
+		return "", nil
+	}
+
+	return metaRefresh(u, p.body, p.contentType)
+}
+
+// metaRefresh returns the URL of a <meta http-equiv="refresh"> tag in
+// an HTML document, or "" if it has none.
+func metaRefresh(base *url.URL, body []byte, contentType string) (string, error) {
+	z := newHTMLTokenizer(decodeHTML(body, contentType))
+	for {
+		t, ok := z.next()
+		if !ok {
+			return "", nil
+		}
+
+		if t.data != "meta" || t.typ == endTagToken || !strings.EqualFold(t.attr("http-equiv"), "refresh") {
+			continue
+		}
+
+		// content="0;URL=https://example.com/"
+		content := t.attr("content")
+		i := indexFold(content, "url=")
+		if i < 0 {
+			continue
+		}
+
+		target := strings.Trim(strings.TrimSpace(content[i+4:]), `'"`)
+		next, err := base.Parse(target)
+		if err != nil {
+			return "", err
+		}
+
+		return next.String(), nil
+	}
+}
+
+// Rewrite replaces the bookmark p with one for the resolved URL,
+// keeping every other field including the original Time, then deletes
+// the short link bookmark. If the resolved URL is already bookmarked
+// the two bookmarks are merged with MergeDuplicates rather than one
+// overwriting the other.
+func Rewrite(p *Post, res *Resolution) error {
+	if res.Final == res.URL {
+		return errors.New("error: url was not redirected")
+	}
+
+	pc := &pacer{interval: RateLimit}
+
+	pc.wait()
+	existing, err := PostsGet(&PostsGetOptions{URL: res.Final})
+	if err != nil {
+		return err
+	}
+
+	pc.wait()
+	err = PostsAdd(rewriteOptions(p, res.Final, existing))
+	if err != nil {
+		return err
+	}
+
+	pc.wait()
+	return PostsDelete(p.Href.String())
+}
+
+// rewriteOptions returns the options for saving p under the URL final,
+// merged with the bookmark already saved there, if any.
+func rewriteOptions(p *Post, final string, existing []*Post) *PostsAddOptions {
+	opt := p.addOptions()
+	if len(existing) > 0 {
+		opt, _ = MergeDuplicates([]*Post{existing[0], p})
+	}
+
+	// An untitled bookmark is titled with its URL, which should be
+	// the resolved one rather than the short link.
+	if opt.Description == opt.URL {
+		opt.Description = final
+	}
+	opt.URL = final
+
+	return opt
+}
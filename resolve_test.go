@@ -0,0 +1,103 @@
+package pinboard
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+)
+
+func TestResolver(t *testing.T) {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/short", func(w http.ResponseWriter, r *http.Request) {
+		http.Redirect(w, r, "/hop", http.StatusMovedPermanently)
+	})
+	mux.HandleFunc("/hop", func(w http.ResponseWriter, r *http.Request) {
+		http.Redirect(w, r, "/article?id=1", http.StatusFound)
+	})
+	mux.HandleFunc("/article", func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, "<title>Article</title>")
+	})
+	mux.HandleFunc("/refresh", func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, `<meta http-equiv="refresh" content="0;URL='/article?id=2'">`)
+	})
+	mux.HandleFunc("/loop", func(w http.ResponseWriter, r *http.Request) {
+		http.Redirect(w, r, "/loop2", http.StatusFound)
+	})
+	mux.HandleFunc("/loop2", func(w http.ResponseWriter, r *http.Request) {
+		http.Redirect(w, r, "/loop", http.StatusFound)
+	})
+
+	srv := httptest.NewServer(mux)
+	defer srv.Close()
+
+	u, _ := url.Parse(srv.URL)
+	r := &Resolver{Shorteners: []string{u.Hostname()}}
+
+	if !r.IsShort(srv.URL+"/short") || r.IsShort("https://example.com/") {
+		t.Error("error: unexpected IsShort result")
+	}
+
+	res, err := r.Resolve(srv.URL + "/short")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if res.Final != srv.URL+"/article?id=1" || len(res.Hops) != 2 {
+		t.Errorf("error: unexpected resolution %+v", res)
+	}
+
+	res, err = r.Resolve(srv.URL + "/refresh")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if res.Final != srv.URL+"/article?id=2" {
+		t.Errorf("error: got %v, expected meta refresh to be followed", res.Final)
+	}
+
+	_, err = r.Resolve(srv.URL + "/loop")
+	if err == nil || !strings.Contains(err.Error(), "loop") {
+		t.Errorf("error: expected redirect loop error, got %v", err)
+	}
+
+	_, err = (&Resolver{MaxHops: 1}).Resolve(srv.URL + "/short")
+	if err == nil {
+		t.Error("error: expected too many redirects error")
+	}
+}
+
+func TestRewriteOptions(t *testing.T) {
+	short := testPost("https://bit.ly/abc", "Article", "short")
+	short.Extended = []byte("Saved from a tweet.")
+
+	opt := rewriteOptions(short, "https://example.com/article", nil)
+	if opt.URL != "https://example.com/article" || !opt.Dt.Equal(short.Time) || !opt.Replace {
+		t.Errorf("error: unexpected options %+v", opt)
+	}
+
+	untitled := testPost("https://bit.ly/abc", "", "short")
+	opt = rewriteOptions(untitled, "https://example.com/article", nil)
+	if opt.Description != "https://example.com/article" {
+		t.Errorf("error: got title %q, expected the resolved url", opt.Description)
+	}
+
+	existing := testPost("https://example.com/article", "An Article", "reading")
+	existing.Time = short.Time.AddDate(1, 0, 0)
+	existing.Extended = []byte("My notes.")
+
+	opt = rewriteOptions(short, "https://example.com/article", []*Post{existing})
+	if opt.URL != "https://example.com/article" || !opt.Dt.Equal(short.Time) {
+		t.Errorf("error: got %v at %v, expected the resolved url at the oldest time", opt.URL, opt.Dt)
+	}
+
+	if strings.Join(opt.Tags, " ") != "short reading" {
+		t.Errorf("error: got tags %v, expected the tags of both bookmarks", opt.Tags)
+	}
+
+	if !strings.Contains(string(opt.Extended), "My notes.") || !strings.Contains(string(opt.Extended), "tweet") {
+		t.Errorf("error: got extended %q, expected the notes of both bookmarks", opt.Extended)
+	}
+}
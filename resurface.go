@@ -0,0 +1,301 @@
+package pinboard
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"math"
+	"math/rand"
+	"os"
+	"sort"
+	"strconv"
+	"time"
+)
+
+// Resurfaced records how often a bookmark has been resurfaced.
+type Resurfaced struct {
+	Count int       `json:"count"`
+	Last  time.Time `json:"last"`
+}
+
+// Resurfacer builds digests of old bookmarks: those saved on the same
+// day in previous years, and a random sample weighted towards old
+// bookmarks that haven't been resurfaced much. It keeps a history of
+// the bookmarks it resurfaced so the sample keeps changing.
+type Resurfacer struct {
+	// Number of bookmarks in the random sample. Defaults to 5.
+	Sample int
+
+	// Bookmarks younger than this, or resurfaced more recently,
+	// aren't sampled. Defaults to 90 days.
+	MinAge time.Duration
+
+	// Time between API calls. Defaults to RateLimit.
+	Interval time.Duration
+
+	// Source of randomness. Defaults to one seeded with the
+	// current time.
+	Rand *rand.Rand
+
+	// File the history is saved to. If empty, history is kept in
+	// memory only.
+	HistoryPath string
+
+	history map[string]Resurfaced
+}
+
+// NewResurfacer returns a Resurfacer that saves its history to
+// historyPath, loading any history saved by a previous run.
+func NewResurfacer(historyPath string) (*Resurfacer, error) {
+	r := &Resurfacer{
+		HistoryPath: historyPath,
+		history:     make(map[string]Resurfaced),
+	}
+
+	if historyPath == "" {
+		return r, nil
+	}
+
+	data, err := ioutil.ReadFile(historyPath)
+	if err != nil && !os.IsNotExist(err) {
+		return nil, err
+	}
+
+	if len(data) > 0 {
+		err = json.Unmarshal(data, &r.history)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return r, nil
+}
+
+// History returns the resurfacing history, keyed by URL.
+func (r *Resurfacer) History() map[string]Resurfaced {
+	return r.history
+}
+
+// save writes the history to HistoryPath.
+func (r *Resurfacer) save() error {
+	if r.HistoryPath == "" {
+		return nil
+	}
+
+	data, err := json.Marshal(r.history)
+	if err != nil {
+		return err
+	}
+
+	return writeFileAtomic(r.HistoryPath, data, 0600)
+}
+
+// YearPosts are the bookmarks saved on a day in a given year.
+type YearPosts struct {
+	Year  int
+	Posts []*Post
+}
+
+// ResurfaceDigest is a digest of old bookmarks.
+type ResurfaceDigest struct {
+	// Day the digest is for.
+	Date time.Time
+
+	// Bookmarks saved on the same day in previous years, most
+	// recent year first.
+	OnThisDay []YearPosts
+
+	// Random sample of old bookmarks.
+	Rediscover []*Post
+}
+
+// OnThisDay returns the bookmarks saved on the same month and day as
+// day in previous years. PostsDates finds the years with bookmarks,
+// and PostsGet fetches each of them.
+func (r *Resurfacer) OnThisDay(day time.Time) ([]YearPosts, error) {
+	dates, err := PostsDates(nil)
+	if err != nil {
+		return nil, err
+	}
+
+	interval := r.Interval
+	if interval == 0 {
+		interval = RateLimit
+	}
+	pc := &pacer{interval: interval}
+
+	var years []YearPosts
+	for _, dt := range sameDay(dates, day) {
+		pc.wait()
+		posts, err := PostsGet(&PostsGetOptions{Dt: dt})
+		if err != nil {
+			return nil, err
+		}
+
+		if len(posts) > 0 {
+			years = append(years, YearPosts{Year: dt.Year(), Posts: posts})
+		}
+	}
+
+	return years, nil
+}
+
+// sameDay returns the dates with bookmarks that fall on the same
+// month and day as day in earlier years, most recent first.
+func sameDay(dates map[string]int, day time.Time) []time.Time {
+	var found []time.Time
+	for d, count := range dates {
+		dt, err := time.Parse("2006-01-02", d)
+		if err != nil || count == 0 {
+			continue
+		}
+
+		if dt.Month() == day.Month() && dt.Day() == day.Day() && dt.Year() < day.Year() {
+			found = append(found, dt)
+		}
+	}
+
+	sort.Slice(found, func(i, j int) bool {
+		return found[i].After(found[j])
+	})
+
+	return found
+}
+
+// Rediscover returns a random sample of posts older than MinAge and
+// not resurfaced within MinAge. Older posts are more likely to be
+// picked, and posts resurfaced before less likely.
+func (r *Resurfacer) Rediscover(posts []*Post, now time.Time) []*Post {
+	n := r.Sample
+	if n == 0 {
+		n = 5
+	}
+
+	minAge := r.MinAge
+	if minAge == 0 {
+		minAge = 90 * 24 * time.Hour
+	}
+
+	rnd := r.Rand
+	if rnd == nil {
+		rnd = rand.New(rand.NewSource(now.UnixNano()))
+	}
+
+	// Weighted sampling without replacement: each post gets the
+	// key u^(1/weight) and the highest keys win.
+	type candidate struct {
+		post *Post
+		key  float64
+	}
+
+	var candidates []candidate
+	for _, p := range posts {
+		age := now.Sub(p.Time)
+		if age < minAge {
+			continue
+		}
+
+		h := r.history[p.Href.String()]
+		if !h.Last.IsZero() && now.Sub(h.Last) < minAge {
+			continue
+		}
+
+		years := age.Hours() / 24 / 365
+		weight := (1 + years) / float64((1+h.Count)*(1+h.Count))
+		candidates = append(candidates, candidate{p, math.Pow(rnd.Float64(), 1/weight)})
+	}
+
+	sort.SliceStable(candidates, func(i, j int) bool {
+		return candidates[i].key > candidates[j].key
+	})
+
+	if len(candidates) > n {
+		candidates = candidates[:n]
+	}
+
+	sample := make([]*Post, len(candidates))
+	for i, c := range candidates {
+		sample[i] = c.post
+	}
+
+	return sample
+}
+
+// Digest builds the digest for day from the account's bookmarks and
+// records the bookmarks in it as resurfaced.
+func (r *Resurfacer) Digest(day time.Time) (*ResurfaceDigest, error) {
+	years, err := r.OnThisDay(day)
+	if err != nil {
+		return nil, err
+	}
+
+	posts, err := PostsAll(nil)
+	if err != nil {
+		return nil, err
+	}
+
+	d := r.digest(day, years, posts)
+
+	return d, r.save()
+}
+
+// digest builds a digest from the bookmarks saved on this day and all
+// bookmarks, leaving the former out of the random sample.
+func (r *Resurfacer) digest(day time.Time, years []YearPosts, posts []*Post) *ResurfaceDigest {
+	d := &ResurfaceDigest{Date: day, OnThisDay: years}
+
+	onThisDay := make(map[string]bool)
+	for _, y := range years {
+		for _, p := range y.Posts {
+			onThisDay[p.Href.String()] = true
+			r.resurfaced(p, day)
+		}
+	}
+
+	var rest []*Post
+	for _, p := range posts {
+		if !onThisDay[p.Href.String()] {
+			rest = append(rest, p)
+		}
+	}
+
+	d.Rediscover = r.Rediscover(rest, day)
+	for _, p := range d.Rediscover {
+		r.resurfaced(p, day)
+	}
+
+	return d
+}
+
+// resurfaced records that p was resurfaced at t.
+func (r *Resurfacer) resurfaced(p *Post, t time.Time) {
+	h := r.history[p.Href.String()]
+	h.Count++
+	h.Last = t.UTC()
+	r.history[p.Href.String()] = h
+}
+
+// Write writes the digest in format f.
+func (d *ResurfaceDigest) Write(w io.Writer, f Format) error {
+	var sections []section
+
+	if len(d.OnThisDay) > 0 {
+		s := section{title: "On this day"}
+		for _, y := range d.OnThisDay {
+			s.sections = append(s.sections, section{
+				title: strconv.Itoa(y.Year),
+				posts: y.Posts,
+			})
+		}
+		sections = append(sections, s)
+	}
+
+	if len(d.Rediscover) > 0 {
+		sections = append(sections, section{title: "Rediscover", posts: d.Rediscover})
+	}
+
+	title := fmt.Sprintf("Bookmarks for %s", d.Date.Format("2 January 2006"))
+
+	return writeSections(w, f, title, sections)
+}
@@ -0,0 +1,103 @@
+package pinboard
+
+import (
+	"bytes"
+	"math/rand"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestSameDay(t *testing.T) {
+	day := time.Date(2016, 12, 11, 0, 0, 0, 0, time.UTC)
+	dates := map[string]int{
+		"2010-12-11": 2,
+		"2014-12-11": 1,
+		"2014-12-12": 4,
+		"2016-12-11": 3,
+	}
+
+	found := sameDay(dates, day)
+	if len(found) != 2 || found[0].Year() != 2014 || found[1].Year() != 2010 {
+		t.Errorf("error: got %v, expected 2014-12-11 and 2010-12-11", found)
+	}
+}
+
+func TestResurfaceDigest(t *testing.T) {
+	day := time.Date(2016, 12, 11, 0, 0, 0, 0, time.UTC)
+
+	anniversary := testPost("https://example.com/anniversary", "Anniversary", "go")
+	old := testPost("https://example.com/old", "Old")
+	old.Time = day.AddDate(-3, 0, 0)
+	seen := testPost("https://example.com/seen", "Seen")
+	seen.Time = day.AddDate(-3, 0, 0)
+	young := testPost("https://example.com/young", "Young")
+	young.Time = day.AddDate(0, 0, -10)
+
+	r, err := NewResurfacer("")
+	if err != nil {
+		t.Fatal(err)
+	}
+	r.Rand = rand.New(rand.NewSource(1))
+	r.history[seen.Href.String()] = Resurfaced{Count: 1, Last: day.AddDate(0, 0, -5)}
+
+	years := []YearPosts{{Year: 2010, Posts: []*Post{anniversary}}}
+	d := r.digest(day, years, []*Post{anniversary, old, seen, young})
+
+	if len(d.Rediscover) != 1 || d.Rediscover[0] != old {
+		t.Fatalf("error: got %v, expected only the old post to be rediscovered", d.Rediscover)
+	}
+
+	if h := r.History()[old.Href.String()]; h.Count != 1 || !h.Last.Equal(day) {
+		t.Errorf("error: got history %+v, expected old post resurfaced once", h)
+	}
+
+	if h := r.History()[anniversary.Href.String()]; h.Count != 1 {
+		t.Errorf("error: got history %+v, expected anniversary resurfaced once", h)
+	}
+
+	for f, expected := range map[Format][]string{
+		FormatMarkdown: {"# Bookmarks for 11 December 2016", "### 2010", "- [Anniversary](<https://example.com/anniversary>) `go`"},
+		FormatHTML:     {"<h1>Bookmarks for 11 December 2016</h1>", "<h3>2010</h3>", `<a href="https://example.com/old">Old</a>`},
+		FormatText:     {"On this day\n-----------", "* Old\n  https://example.com/old"},
+	} {
+		var buf bytes.Buffer
+		err := d.Write(&buf, f)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		for _, s := range expected {
+			if !strings.Contains(buf.String(), s) {
+				t.Errorf("error: expected %q in %s digest:\n%s", s, f, buf.String())
+			}
+		}
+	}
+
+	err = d.Write(&bytes.Buffer{}, Format("pdf"))
+	if err == nil {
+		t.Error("error: expected unknown format error")
+	}
+}
+
+func TestRediscoverWeighting(t *testing.T) {
+	now := time.Date(2016, 12, 11, 0, 0, 0, 0, time.UTC)
+
+	ancient := testPost("https://example.com/ancient", "Ancient")
+	ancient.Time = now.AddDate(-10, 0, 0)
+	recent := testPost("https://example.com/recent", "Recent")
+	recent.Time = now.AddDate(0, -6, 0)
+
+	r, _ := NewResurfacer("")
+	r.Rand = rand.New(rand.NewSource(1))
+	r.Sample = 1
+
+	picked := make(map[*Post]int)
+	for i := 0; i < 1000; i++ {
+		picked[r.Rediscover([]*Post{ancient, recent}, now)[0]]++
+	}
+
+	if picked[ancient] <= picked[recent] {
+		t.Errorf("error: got %v ancient and %v recent picks, expected older posts to be favoured", picked[ancient], picked[recent])
+	}
+}
@@ -0,0 +1,487 @@
+package pinboard
+
+import (
+	"math"
+	"sort"
+	"strings"
+	"sync"
+	"unicode"
+)
+
+// BM25 parameters.
+const (
+	bm25K1 = 1.2
+	bm25B  = 0.75
+)
+
+// Fields of a post that are indexed, in the order they are stored in
+// a document, along with the weight of a term found in each.
+const (
+	fieldDescription = iota
+	fieldExtended
+	fieldTags
+	fieldHref
+	numFields
+)
+
+var fieldWeights = [numFields]float64{
+	fieldDescription: 2,
+	fieldExtended:    1,
+	fieldTags:        2,
+	fieldHref:        1,
+}
+
+// token is a normalised term along with its byte offsets in the text
+// it came from.
+type token struct {
+	term       string
+	start, end int
+}
+
+// tokenize splits text into lower case terms made of letters and
+// digits.
+func tokenize(text string) []token {
+	var tokens []token
+
+	start := -1
+	for i, r := range text {
+		if unicode.IsLetter(r) || unicode.IsDigit(r) {
+			if start < 0 {
+				start = i
+			}
+			continue
+		}
+
+		if start >= 0 {
+			tokens = append(tokens, token{strings.ToLower(text[start:i]), start, i})
+			start = -1
+		}
+	}
+
+	if start >= 0 {
+		tokens = append(tokens, token{strings.ToLower(text[start:]), start, len(text)})
+	}
+
+	return tokens
+}
+
+// terms returns just the terms of tokens.
+func terms(tokens []token) []string {
+	t := make([]string, len(tokens))
+	for i := range tokens {
+		t[i] = tokens[i].term
+	}
+
+	return t
+}
+
+// document is an indexed post.
+type document struct {
+	post   *Post
+	fields [numFields][]string
+	length float64
+}
+
+// SearchResult is a post matching a search, best matches first.
+type SearchResult struct {
+	Post  *Post
+	Score float64
+
+	// Text around the first match with matching terms
+	// highlighted.
+	Snippet string
+}
+
+// Index is an in-memory full-text index of posts ranked with BM25.
+// It indexes the title (Description), Extended, Tags and the host and
+// path of Href. It is safe for concurrent use.
+type Index struct {
+	// Strings placed around matching terms in snippets. Default to
+	// "**". Snippets are not escaped.
+	HighlightOpen, HighlightClose string
+
+	mu       sync.RWMutex
+	docs     map[string]*document
+	postings map[string]map[string]float64
+	vocab    []string
+	sorted   bool
+	total    float64
+}
+
+// NewIndex returns an index of posts, for example the result of
+// PostsAll.
+func NewIndex(posts []*Post) *Index {
+	idx := &Index{
+		docs:     make(map[string]*document),
+		postings: make(map[string]map[string]float64),
+	}
+
+	for _, p := range posts {
+		idx.Add(p)
+	}
+
+	return idx
+}
+
+// Len returns the number of indexed posts.
+func (idx *Index) Len() int {
+	idx.mu.RLock()
+	defer idx.mu.RUnlock()
+
+	return len(idx.docs)
+}
+
+// Add indexes p, replacing any post already indexed with the same URL.
+func (idx *Index) Add(p *Post) {
+	idx.mu.Lock()
+	defer idx.mu.Unlock()
+
+	key := p.Href.String()
+	idx.remove(key)
+
+	d := &document{post: p}
+	d.fields[fieldDescription] = terms(tokenize(p.Description))
+	d.fields[fieldExtended] = terms(tokenize(string(p.Extended)))
+	d.fields[fieldTags] = terms(tokenize(strings.Join(p.Tags, " ")))
+	d.fields[fieldHref] = terms(tokenize(p.Href.Host + " " + p.Href.Path))
+
+	for f, fieldTerms := range d.fields {
+		for _, term := range fieldTerms {
+			postings, ok := idx.postings[term]
+			if !ok {
+				postings = make(map[string]float64)
+				idx.postings[term] = postings
+				idx.sorted = false
+			}
+			postings[key] += fieldWeights[f]
+			d.length += fieldWeights[f]
+		}
+	}
+
+	idx.docs[key] = d
+	idx.total += d.length
+}
+
+// Remove drops the post with the given URL from the index.
+func (idx *Index) Remove(url string) {
+	idx.mu.Lock()
+	defer idx.mu.Unlock()
+
+	idx.remove(url)
+}
+
+// Apply updates the index with a change reported by a Watcher.
+func (idx *Index) Apply(e Event) {
+	if e.Type == EventDeleted {
+		idx.Remove(e.URL)
+		return
+	}
+
+	idx.Add(e.Post)
+}
+
+// remove drops a document. The caller must hold the write lock.
+func (idx *Index) remove(key string) {
+	d, ok := idx.docs[key]
+	if !ok {
+		return
+	}
+
+	for _, fieldTerms := range d.fields {
+		for _, term := range fieldTerms {
+			postings := idx.postings[term]
+			delete(postings, key)
+			if len(postings) == 0 {
+				delete(idx.postings, term)
+				idx.sorted = false
+			}
+		}
+	}
+
+	delete(idx.docs, key)
+	idx.total -= d.length
+}
+
+// searchTerm is a single clause of a search: a word, a prefix
+// ("word*") or a quoted phrase.
+type searchTerm struct {
+	words  []string
+	prefix bool
+}
+
+// parseSearch splits a search string into clauses.
+func parseSearch(q string) []searchTerm {
+	var clauses []searchTerm
+
+	for len(q) > 0 {
+		q = strings.TrimLeft(q, " \t\n")
+		if q == "" {
+			break
+		}
+
+		if q[0] == '"' {
+			end := strings.IndexByte(q[1:], '"')
+			phrase := q[1:]
+			if end >= 0 {
+				phrase = q[1 : end+1]
+				q = q[end+2:]
+			} else {
+				q = ""
+			}
+
+			words := terms(tokenize(phrase))
+			if len(words) > 0 {
+				clauses = append(clauses, searchTerm{words: words})
+			}
+			continue
+		}
+
+		end := strings.IndexAny(q, " \t\n")
+		word := q
+		if end >= 0 {
+			word, q = q[:end], q[end:]
+		} else {
+			q = ""
+		}
+
+		prefix := strings.HasSuffix(word, "*")
+		for _, t := range terms(tokenize(word)) {
+			clauses = append(clauses, searchTerm{words: []string{t}})
+		}
+		if prefix && len(clauses) > 0 {
+			clauses[len(clauses)-1].prefix = true
+		}
+	}
+
+	return clauses
+}
+
+// expand returns the indexed terms matching a word. The caller must
+// hold the read lock with idx.vocab sorted.
+func (idx *Index) expand(word string, prefix bool) []string {
+	if !prefix {
+		if _, ok := idx.postings[word]; ok {
+			return []string{word}
+		}
+		return nil
+	}
+
+	var matches []string
+	i := sort.SearchStrings(idx.vocab, word)
+	for ; i < len(idx.vocab) && strings.HasPrefix(idx.vocab[i], word); i++ {
+		matches = append(matches, idx.vocab[i])
+	}
+
+	return matches
+}
+
+// sortVocab rebuilds the sorted term list used for prefix matching
+// if terms were added or removed since it was last built.
+func (idx *Index) sortVocab() {
+	idx.mu.Lock()
+	defer idx.mu.Unlock()
+
+	if idx.sorted {
+		return
+	}
+
+	idx.vocab = idx.vocab[:0]
+	for term := range idx.postings {
+		idx.vocab = append(idx.vocab, term)
+	}
+	sort.Strings(idx.vocab)
+	idx.sorted = true
+}
+
+// Search returns the posts matching every word, prefix ("go*") and
+// quoted phrase in q, ranked with BM25.
+func (idx *Index) Search(q string) []SearchResult {
+	clauses := parseSearch(q)
+	if len(clauses) == 0 {
+		return nil
+	}
+
+	idx.sortVocab()
+
+	idx.mu.RLock()
+	defer idx.mu.RUnlock()
+
+	n := float64(len(idx.docs))
+	avg := idx.total / n
+
+	scores := make(map[string]float64)
+	var highlight []string
+	for i, c := range clauses {
+		matched := make(map[string]float64)
+		for j, word := range c.words {
+			prefix := c.prefix && j == len(c.words)-1
+			expanded := idx.expand(word, prefix)
+			highlight = append(highlight, expanded...)
+
+			// Term frequency of the word (or all terms
+			// matching the prefix) in each document.
+			tf := make(map[string]float64)
+			for _, term := range expanded {
+				for key, f := range idx.postings[term] {
+					tf[key] += f
+				}
+			}
+
+			idf := math.Log(1 + (n-float64(len(tf))+0.5)/(float64(len(tf))+0.5))
+			for key, f := range tf {
+				if j > 0 {
+					if _, ok := matched[key]; !ok {
+						continue
+					}
+				}
+
+				d := idx.docs[key]
+				norm := f + bm25K1*(1-bm25B+bm25B*d.length/avg)
+				matched[key] += idf * f * (bm25K1 + 1) / norm
+			}
+
+			// Every word of a phrase must match.
+			if j > 0 {
+				for key := range matched {
+					if _, ok := tf[key]; !ok {
+						delete(matched, key)
+					}
+				}
+			}
+		}
+
+		if len(c.words) > 1 {
+			for key := range matched {
+				if !idx.docs[key].hasPhrase(c.words) {
+					delete(matched, key)
+				}
+			}
+		}
+
+		// Every clause must match.
+		for key, s := range matched {
+			if _, ok := scores[key]; ok || i == 0 {
+				scores[key] += s
+			}
+		}
+		for key := range scores {
+			if _, ok := matched[key]; !ok {
+				delete(scores, key)
+			}
+		}
+	}
+
+	results := make([]SearchResult, 0, len(scores))
+	for key, s := range scores {
+		p := idx.docs[key].post
+		results = append(results, SearchResult{
+			Post:    p,
+			Score:   s,
+			Snippet: idx.snippet(p, highlight),
+		})
+	}
+
+	sort.Slice(results, func(i, j int) bool {
+		if results[i].Score != results[j].Score {
+			return results[i].Score > results[j].Score
+		}
+		return results[i].Post.Href.String() < results[j].Post.Href.String()
+	})
+
+	return results
+}
+
+// hasPhrase reports whether words appear next to each other, in
+// order, in any field of d.
+func (d *document) hasPhrase(words []string) bool {
+	for _, fieldTerms := range d.fields {
+	next:
+		for i := 0; i+len(words) <= len(fieldTerms); i++ {
+			for j, w := range words {
+				if fieldTerms[i+j] != w {
+					continue next
+				}
+			}
+			return true
+		}
+	}
+
+	return false
+}
+
+// snippetWords is the number of words shown in a snippet.
+const snippetWords = 24
+
+// snippet returns a short piece of the post's Extended text, or its
+// title if Extended doesn't match, around the first matching term.
+func (idx *Index) snippet(p *Post, highlight []string) string {
+	match := make(map[string]bool)
+	for _, h := range highlight {
+		match[h] = true
+	}
+
+	openMark, closeMark := idx.HighlightOpen, idx.HighlightClose
+	if openMark == "" && closeMark == "" {
+		openMark, closeMark = "**", "**"
+	}
+
+	text := string(p.Extended)
+	tokens := tokenize(text)
+	first := -1
+	for i, t := range tokens {
+		if match[t.term] {
+			first = i
+			break
+		}
+	}
+
+	if first < 0 {
+		text = p.Description
+		tokens = tokenize(text)
+		first = 0
+	}
+
+	if len(tokens) == 0 {
+		return text
+	}
+
+	from := first - snippetWords/4
+	if from < 0 {
+		from = 0
+	}
+	to := from + snippetWords
+	if to > len(tokens) {
+		to = len(tokens)
+		from = to - snippetWords
+		if from < 0 {
+			from = 0
+		}
+	}
+
+	var b strings.Builder
+	if from > 0 {
+		b.WriteString("…")
+	}
+
+	pos := 0
+	if from > 0 {
+		pos = tokens[from].start
+	}
+	for _, t := range tokens[from:to] {
+		b.WriteString(text[pos:t.start])
+		if match[t.term] {
+			b.WriteString(openMark + text[t.start:t.end] + closeMark)
+		} else {
+			b.WriteString(text[t.start:t.end])
+		}
+		pos = t.end
+	}
+
+	if to < len(tokens) {
+		b.WriteString("…")
+	} else {
+		b.WriteString(text[pos:])
+	}
+
+	return b.String()
+}
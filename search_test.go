@@ -0,0 +1,83 @@
+package pinboard
+
+import (
+	"testing"
+)
+
+func testIndex() *Index {
+	gopher := testPost("https://golang.org/doc/effective_go", "Effective Go", "go", "programming")
+	gopher.Extended = []byte("Tips for writing clear, idiomatic Go code.")
+
+	pinboard := testPost("https://pinboard.in/api/", "Pinboard API", "bookmarks", "api")
+	pinboard.Extended = []byte("The Pinboard API is a way to interact programatically with your bookmarks.")
+
+	rust := testPost("https://www.rust-lang.org/", "Rust programming language", "rust", "programming")
+	rust.Extended = []byte("A language empowering everyone to build reliable and efficient software.")
+
+	return NewIndex([]*Post{gopher, pinboard, rust})
+}
+
+func TestIndexSearch(t *testing.T) {
+	idx := testIndex()
+
+	results := idx.Search("programming")
+	if len(results) != 2 {
+		t.Fatalf("error: got %v, expected 2 results", len(results))
+	}
+
+	// Matches title and tag, so ranks above effective_go which
+	// only matches a tag.
+	if results[0].Post.Href.Host != "www.rust-lang.org" {
+		t.Errorf("error: got %v, expected rust-lang.org first", results[0].Post.Href)
+	}
+
+	results = idx.Search("bookmark*")
+	if len(results) != 1 || results[0].Post.Description != "Pinboard API" {
+		t.Errorf("error: expected prefix match of Pinboard API, got %v", results)
+	}
+
+	expected := "The Pinboard API is a way to interact programatically with your **bookmarks**."
+	if len(results) == 1 && results[0].Snippet != expected {
+		t.Errorf("error: got snippet %q, expected %q", results[0].Snippet, expected)
+	}
+
+	results = idx.Search(`"idiomatic go"`)
+	if len(results) != 1 {
+		t.Errorf("error: got %v, expected 1 phrase match", len(results))
+	}
+
+	results = idx.Search(`"go idiomatic"`)
+	if len(results) != 0 {
+		t.Errorf("error: got %v, expected no phrase match", len(results))
+	}
+
+	results = idx.Search("language golang")
+	if len(results) != 0 {
+		t.Errorf("error: got %v, expected every term to be required", len(results))
+	}
+
+	results = idx.Search("golang")
+	if len(results) != 1 {
+		t.Errorf("error: got %v, expected host match", len(results))
+	}
+}
+
+func TestIndexIncremental(t *testing.T) {
+	idx := testIndex()
+
+	idx.Remove("https://pinboard.in/api/")
+	if idx.Len() != 2 || len(idx.Search("pinboard")) != 0 {
+		t.Error("error: expected removed post to be gone from the index")
+	}
+
+	renamed := testPost("https://golang.org/doc/effective_go", "Writing Go well", "go")
+	idx.Apply(Event{Type: EventModified, URL: renamed.Href.String(), Post: renamed})
+
+	if idx.Len() != 2 || len(idx.Search("idiomatic")) != 0 {
+		t.Error("error: expected modified post to be reindexed")
+	}
+
+	if len(idx.Search("well")) != 1 {
+		t.Error("error: expected new title to be indexed")
+	}
+}
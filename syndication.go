@@ -0,0 +1,227 @@
+package pinboard
+
+import (
+	"crypto/sha1"
+	"encoding/json"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"time"
+)
+
+// FeedOptions describes a feed written by WriteAtom or WriteJSONFeed.
+type FeedOptions struct {
+	// Required: title of the feed.
+	Title string
+
+	// URL of the web page the feed belongs to. Optional.
+	HomeURL string
+
+	// URL the feed is published at. Optional, but recommended as
+	// it is also used as the feed's ID.
+	FeedURL string
+
+	// Author of the feed. Defaults to "Pinboard".
+	Author string
+
+	// Leave out bookmarks that aren't shared.
+	PublicOnly bool
+}
+
+// feedIDPrefix starts the tag URIs (RFC 4151) used as IDs, so that an
+// entry keeps its ID whichever feed it appears in.
+const feedIDPrefix = "tag:pinboard.in,2009:"
+
+// entryID returns the stable ID of a bookmark's feed entry.
+func entryID(p *Post) string {
+	return feedIDPrefix + postHash(p)
+}
+
+// feedPosts returns the posts to include in a feed and the time the
+// feed was last updated, that of its newest post.
+func feedPosts(posts []*Post, opt *FeedOptions) ([]*Post, time.Time, error) {
+	if opt == nil || opt.Title == "" {
+		return nil, time.Time{}, errors.New("error: missing feed title")
+	}
+
+	var included []*Post
+	var updated time.Time
+	for _, p := range posts {
+		if opt.PublicOnly && !p.Shared {
+			continue
+		}
+
+		included = append(included, p)
+		if p.Time.After(updated) {
+			updated = p.Time
+		}
+	}
+
+	if updated.IsZero() {
+		updated = time.Now()
+	}
+
+	return included, updated.UTC(), nil
+}
+
+type atomFeed struct {
+	XMLName xml.Name    `xml:"http://www.w3.org/2005/Atom feed"`
+	Title   string      `xml:"title"`
+	ID      string      `xml:"id"`
+	Updated string      `xml:"updated"`
+	Author  atomPerson  `xml:"author"`
+	Links   []atomLink  `xml:"link"`
+	Entries []atomEntry `xml:"entry"`
+}
+
+type atomPerson struct {
+	Name string `xml:"name"`
+}
+
+type atomLink struct {
+	Rel  string `xml:"rel,attr"`
+	Href string `xml:"href,attr"`
+}
+
+type atomText struct {
+	Type string `xml:"type,attr"`
+	Body string `xml:",chardata"`
+}
+
+type atomCategory struct {
+	Term string `xml:"term,attr"`
+}
+
+type atomEntry struct {
+	Title      string         `xml:"title"`
+	ID         string         `xml:"id"`
+	Updated    string         `xml:"updated"`
+	Published  string         `xml:"published"`
+	Link       atomLink       `xml:"link"`
+	Summary    *atomText      `xml:"summary,omitempty"`
+	Categories []atomCategory `xml:"category"`
+}
+
+// WriteAtom writes posts as an Atom 1.0 feed, in the order given.
+func WriteAtom(w io.Writer, posts []*Post, opt *FeedOptions) error {
+	posts, updated, err := feedPosts(posts, opt)
+	if err != nil {
+		return err
+	}
+
+	feed := atomFeed{
+		Title:   opt.Title,
+		ID:      opt.FeedURL,
+		Updated: updated.Format(time.RFC3339),
+		Author:  atomPerson{Name: firstNonEmpty(opt.Author, "Pinboard")},
+	}
+
+	if feed.ID == "" {
+		feed.ID = fmt.Sprintf("%sfeed:%x", feedIDPrefix, sha1.Sum([]byte(opt.Title)))
+	}
+	if opt.FeedURL != "" {
+		feed.Links = append(feed.Links, atomLink{Rel: "self", Href: opt.FeedURL})
+	}
+	if opt.HomeURL != "" {
+		feed.Links = append(feed.Links, atomLink{Rel: "alternate", Href: opt.HomeURL})
+	}
+
+	for _, p := range posts {
+		entry := atomEntry{
+			Title:     firstNonEmpty(p.Description, p.Href.String()),
+			ID:        entryID(p),
+			Updated:   p.Time.UTC().Format(time.RFC3339),
+			Published: p.Time.UTC().Format(time.RFC3339),
+			Link:      atomLink{Rel: "alternate", Href: p.Href.String()},
+		}
+
+		if len(p.Extended) > 0 {
+			entry.Summary = &atomText{Type: "text", Body: string(p.Extended)}
+		}
+
+		for _, t := range cleanTags(p.Tags) {
+			entry.Categories = append(entry.Categories, atomCategory{Term: t})
+		}
+
+		feed.Entries = append(feed.Entries, entry)
+	}
+
+	_, err = io.WriteString(w, xml.Header)
+	if err != nil {
+		return err
+	}
+
+	enc := xml.NewEncoder(w)
+	enc.Indent("", "  ")
+	err = enc.Encode(feed)
+	if err != nil {
+		return err
+	}
+
+	_, err = io.WriteString(w, "\n")
+	return err
+}
+
+// JSONFeedVersion is the version of JSON Feed written by WriteJSONFeed.
+const JSONFeedVersion = "https://jsonfeed.org/version/1.1"
+
+type jsonFeed struct {
+	Version     string           `json:"version"`
+	Title       string           `json:"title"`
+	HomePageURL string           `json:"home_page_url,omitempty"`
+	FeedURL     string           `json:"feed_url,omitempty"`
+	Authors     []jsonFeedAuthor `json:"authors"`
+	Items       []jsonFeedItem   `json:"items"`
+}
+
+type jsonFeedAuthor struct {
+	Name string `json:"name"`
+}
+
+type jsonFeedItem struct {
+	ID            string   `json:"id"`
+	URL           string   `json:"url"`
+	Title         string   `json:"title"`
+	ContentText   string   `json:"content_text"`
+	DatePublished string   `json:"date_published"`
+	DateModified  string   `json:"date_modified"`
+	Tags          []string `json:"tags,omitempty"`
+}
+
+// WriteJSONFeed writes posts as a JSON Feed 1.1 document, in the order
+// given. Items without an extended description use the bookmark's
+// title as their content, which JSON Feed requires.
+func WriteJSONFeed(w io.Writer, posts []*Post, opt *FeedOptions) error {
+	posts, _, err := feedPosts(posts, opt)
+	if err != nil {
+		return err
+	}
+
+	feed := jsonFeed{
+		Version:     JSONFeedVersion,
+		Title:       opt.Title,
+		HomePageURL: opt.HomeURL,
+		FeedURL:     opt.FeedURL,
+		Authors:     []jsonFeedAuthor{{Name: firstNonEmpty(opt.Author, "Pinboard")}},
+		Items:       []jsonFeedItem{},
+	}
+
+	for _, p := range posts {
+		title := firstNonEmpty(p.Description, p.Href.String())
+
+		feed.Items = append(feed.Items, jsonFeedItem{
+			ID:            entryID(p),
+			URL:           p.Href.String(),
+			Title:         title,
+			ContentText:   firstNonEmpty(string(p.Extended), title),
+			DatePublished: p.Time.UTC().Format(time.RFC3339),
+			DateModified:  p.Time.UTC().Format(time.RFC3339),
+			Tags:          cleanTags(p.Tags),
+		})
+	}
+
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(feed)
+}
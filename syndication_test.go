@@ -0,0 +1,162 @@
+package pinboard
+
+import (
+	"bytes"
+	"encoding/json"
+	"encoding/xml"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestWriteAtom(t *testing.T) {
+	public := testPost("https://golang.org/", "Go & friends", "go", "lang")
+	public.Shared = true
+	public.Extended = []byte("The Go <programming> language.")
+	private := testPost("https://example.com/private", "Private")
+	posts := []*Post{public, private}
+
+	tests := []struct {
+		opt *FeedOptions
+		ids []string
+	}{
+		{
+			&FeedOptions{Title: "Bookmarks", FeedURL: "https://example.com/feed.atom"},
+			[]string{
+				"tag:pinboard.in,2009:12fc1b9dc2d516422a91f6aad8e53696",
+				"tag:pinboard.in,2009:52e9a84f47b7b74fa59828acc67f575f",
+			},
+		},
+		{
+			&FeedOptions{Title: "Public", FeedURL: "https://example.com/feed.atom", PublicOnly: true},
+			[]string{"tag:pinboard.in,2009:12fc1b9dc2d516422a91f6aad8e53696"},
+		},
+	}
+
+	for _, test := range tests {
+		var buf bytes.Buffer
+		err := WriteAtom(&buf, posts, test.opt)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		var feed atomFeed
+		err = xml.Unmarshal(buf.Bytes(), &feed)
+		if err != nil {
+			t.Fatalf("error: invalid atom: %s\n%s", err, buf.String())
+		}
+
+		if feed.ID != "https://example.com/feed.atom" || feed.Updated != "2010-12-11T19:48:02Z" {
+			t.Errorf("error: got feed id %q updated %q", feed.ID, feed.Updated)
+		}
+
+		var ids []string
+		for _, e := range feed.Entries {
+			ids = append(ids, e.ID)
+		}
+		if !reflect.DeepEqual(ids, test.ids) {
+			t.Fatalf("error: %s: got entry ids %v, expected %v", test.opt.Title, ids, test.ids)
+		}
+
+		e := feed.Entries[0]
+		if e.Title != "Go & friends" || e.Summary == nil || e.Summary.Body != "The Go <programming> language." {
+			t.Errorf("error: got entry %+v", e)
+		}
+
+		if len(e.Categories) != 2 || e.Categories[0].Term != "go" || e.Categories[1].Term != "lang" {
+			t.Errorf("error: got categories %v", e.Categories)
+		}
+	}
+
+	err := WriteAtom(&bytes.Buffer{}, posts, nil)
+	if err == nil {
+		t.Error("error: expected missing title error")
+	}
+}
+
+func TestWriteJSONFeed(t *testing.T) {
+	public := testPost("https://golang.org/", "Go & friends", "go", "lang")
+	public.Shared = true
+	private := testPost("https://example.com/private", "Private")
+	posts := []*Post{public, private}
+
+	tests := []struct {
+		opt  *FeedOptions
+		urls []string
+		ids  []string
+	}{
+		{
+			&FeedOptions{Title: "Bookmarks"},
+			[]string{"https://golang.org/", "https://example.com/private"},
+			[]string{
+				"tag:pinboard.in,2009:12fc1b9dc2d516422a91f6aad8e53696",
+				"tag:pinboard.in,2009:52e9a84f47b7b74fa59828acc67f575f",
+			},
+		},
+		{
+			&FeedOptions{Title: "Public", PublicOnly: true},
+			[]string{"https://golang.org/"},
+			[]string{"tag:pinboard.in,2009:12fc1b9dc2d516422a91f6aad8e53696"},
+		},
+	}
+
+	for _, test := range tests {
+		var buf bytes.Buffer
+		err := WriteJSONFeed(&buf, posts, test.opt)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		var feed jsonFeed
+		err = json.Unmarshal(buf.Bytes(), &feed)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if feed.Version != JSONFeedVersion {
+			t.Errorf("error: got version %q", feed.Version)
+		}
+
+		var urls, ids []string
+		for _, item := range feed.Items {
+			urls = append(urls, item.URL)
+			ids = append(ids, item.ID)
+
+			if item.DatePublished != "2010-12-11T19:48:02Z" {
+				t.Errorf("error: got item %+v", item)
+			}
+		}
+
+		if !reflect.DeepEqual(urls, test.urls) || !reflect.DeepEqual(ids, test.ids) {
+			t.Errorf("error: %s: got items %v %v, expected %v %v", test.opt.Title, urls, ids, test.urls, test.ids)
+		}
+
+		if len(feed.Items[0].Tags) != 2 {
+			t.Errorf("error: got tags %v", feed.Items[0].Tags)
+		}
+	}
+}
+
+func TestFeedsUntagged(t *testing.T) {
+	posts := []*Post{apiPost("https://golang.org/", "Go", "")}
+
+	var buf bytes.Buffer
+	err := WriteAtom(&buf, posts, &FeedOptions{Title: "Bookmarks"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if strings.Contains(buf.String(), "<category") {
+		t.Errorf("error: expected no categories in %s", buf.String())
+	}
+
+	buf.Reset()
+	err = WriteJSONFeed(&buf, posts, &FeedOptions{Title: "Bookmarks"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if strings.Contains(buf.String(), `"tags"`) {
+		t.Errorf("error: expected no tags in %s", buf.String())
+	}
+}
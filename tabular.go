@@ -0,0 +1,312 @@
+package pinboard
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Columns is the stable set of columns written by CSVWriter and
+// JSONLWriter, in order:
+//
+//	href         URL of the bookmark
+//	description  title
+//	extended     description
+//	tags         space separated in CSV, an array in JSON Lines
+//	shared       "yes" or "no" in CSV, a boolean in JSON Lines
+//	toread       "yes" or "no" in CSV, a boolean in JSON Lines
+//	time         RFC 3339 time the bookmark was saved, in UTC
+//	hash         hex MD5 of the URL
+//	meta         change detection signature
+//	others       number of other users who saved the URL
+//
+// Columns will only ever be added to the end of the list.
+var Columns = []string{
+	"href", "description", "extended", "tags", "shared",
+	"toread", "time", "hash", "meta", "others",
+}
+
+// checkColumns returns the columns to write, all of them if none are
+// given, or an error for an unknown column.
+func checkColumns(columns []string) ([]string, error) {
+	if len(columns) == 0 {
+		return Columns, nil
+	}
+
+	for _, c := range columns {
+		known := false
+		for _, k := range Columns {
+			if c == k {
+				known = true
+				break
+			}
+		}
+
+		if !known {
+			return nil, fmt.Errorf("error: unknown column %q", c)
+		}
+	}
+
+	return columns, nil
+}
+
+// column returns the value of a column as CSV text.
+func column(p *Post, c string) string {
+	switch c {
+	case "href":
+		return p.Href.String()
+	case "description":
+		return p.Description
+	case "extended":
+		return string(p.Extended)
+	case "tags":
+		return strings.Join(cleanTags(p.Tags), " ")
+	case "shared":
+		return yesNo(p.Shared)
+	case "toread":
+		return yesNo(p.Toread)
+	case "time":
+		return p.Time.UTC().Format(time.RFC3339)
+	case "hash":
+		return string(p.Hash)
+	case "meta":
+		return string(p.Meta)
+	case "others":
+		return strconv.Itoa(p.Others)
+	}
+
+	return ""
+}
+
+// CSVWriter writes posts as RFC 4180 CSV with a header row.
+type CSVWriter struct {
+	w       *csv.Writer
+	columns []string
+	started bool
+}
+
+// NewCSVWriter returns a CSVWriter writing the given columns to w, or
+// all of Columns if none are given.
+func NewCSVWriter(w io.Writer, columns ...string) (*CSVWriter, error) {
+	columns, err := checkColumns(columns)
+	if err != nil {
+		return nil, err
+	}
+
+	cw := csv.NewWriter(w)
+	cw.UseCRLF = true
+
+	return &CSVWriter{w: cw, columns: columns}, nil
+}
+
+// Write writes a single post, preceded by the header row if it is the
+// first.
+func (cw *CSVWriter) Write(p *Post) error {
+	if !cw.started {
+		err := cw.w.Write(cw.columns)
+		if err != nil {
+			return err
+		}
+		cw.started = true
+	}
+
+	record := make([]string, len(cw.columns))
+	for i, c := range cw.columns {
+		record[i] = column(p, c)
+	}
+
+	return cw.w.Write(record)
+}
+
+// Flush writes any buffered data to the underlying writer.
+func (cw *CSVWriter) Flush() error {
+	cw.w.Flush()
+	return cw.w.Error()
+}
+
+// CSVReader reads posts written by CSVWriter, or any CSV file with a
+// header row naming some of Columns, as options for adding them
+// again. Unknown columns are ignored; href and description are
+// required.
+type CSVReader struct {
+	r     *csv.Reader
+	index map[string]int
+
+	// Number of the last record read, the header being 1.
+	record int
+}
+
+// NewCSVReader returns a CSVReader reading from r, after reading the
+// header row.
+func NewCSVReader(r io.Reader) (*CSVReader, error) {
+	cr := csv.NewReader(r)
+	cr.FieldsPerRecord = -1
+
+	header, err := cr.Read()
+	if err != nil {
+		return nil, err
+	}
+
+	index := make(map[string]int)
+	for i, c := range header {
+		index[strings.ToLower(strings.TrimSpace(c))] = i
+	}
+
+	for _, c := range []string{"href", "description"} {
+		if _, ok := index[c]; !ok {
+			return nil, fmt.Errorf("error: missing %s column", c)
+		}
+	}
+
+	return &CSVReader{r: cr, index: index, record: 1}, nil
+}
+
+// Read returns the next post, or io.EOF when there are no more.
+func (cr *CSVReader) Read() (*PostsAddOptions, error) {
+	record, err := cr.r.Read()
+	if err != nil {
+		return nil, err
+	}
+	cr.record++
+
+	get := func(c string) string {
+		i, ok := cr.index[c]
+		if !ok || i >= len(record) {
+			return ""
+		}
+		return record[i]
+	}
+
+	opt := &PostsAddOptions{
+		URL:         get("href"),
+		Description: get("description"),
+		Extended:    []byte(get("extended")),
+		Tags:        strings.Fields(get("tags")),
+	}
+
+	opt.Shared, err = parseYesNo(get("shared"))
+	if err == nil {
+		opt.Toread, err = parseYesNo(get("toread"))
+	}
+	if err == nil && get("time") != "" {
+		opt.Dt, err = time.Parse(time.RFC3339, get("time"))
+	}
+	if err != nil {
+		return nil, fmt.Errorf("error: record %d: %s", cr.record, err)
+	}
+
+	return opt, nil
+}
+
+// parseYesNo parses a boolean written as yes or no, or in any form
+// strconv.ParseBool accepts. Empty means no.
+func parseYesNo(s string) (bool, error) {
+	switch strings.ToLower(strings.TrimSpace(s)) {
+	case "", "no":
+		return false, nil
+	case "yes":
+		return true, nil
+	}
+
+	return strconv.ParseBool(s)
+}
+
+// JSONLWriter writes posts as JSON Lines, one object per line.
+type JSONLWriter struct {
+	w       *bufio.Writer
+	columns []string
+}
+
+// NewJSONLWriter returns a JSONLWriter writing the given columns to
+// w, or all of Columns if none are given. Keys are written in the
+// order of the columns.
+func NewJSONLWriter(w io.Writer, columns ...string) (*JSONLWriter, error) {
+	columns, err := checkColumns(columns)
+	if err != nil {
+		return nil, err
+	}
+
+	return &JSONLWriter{w: bufio.NewWriter(w), columns: columns}, nil
+}
+
+// Write writes a single post.
+func (jw *JSONLWriter) Write(p *Post) error {
+	var b bytes.Buffer
+	b.WriteByte('{')
+
+	for i, c := range jw.columns {
+		var v interface{}
+		switch c {
+		case "tags":
+			tags := cleanTags(p.Tags)
+			if tags == nil {
+				tags = []string{}
+			}
+			v = tags
+		case "shared":
+			v = p.Shared
+		case "toread":
+			v = p.Toread
+		case "others":
+			v = p.Others
+		default:
+			v = column(p, c)
+		}
+
+		value, err := json.Marshal(v)
+		if err != nil {
+			return err
+		}
+
+		if i > 0 {
+			b.WriteByte(',')
+		}
+		fmt.Fprintf(&b, "%q:%s", c, value)
+	}
+
+	b.WriteString("}\n")
+
+	_, err := jw.w.Write(b.Bytes())
+	return err
+}
+
+// Flush writes any buffered data to the underlying writer.
+func (jw *JSONLWriter) Flush() error {
+	return jw.w.Flush()
+}
+
+// JSONLReader reads posts written by JSONLWriter as options for
+// adding them again.
+type JSONLReader struct {
+	dec *json.Decoder
+}
+
+// NewJSONLReader returns a JSONLReader reading from r.
+func NewJSONLReader(r io.Reader) *JSONLReader {
+	return &JSONLReader{dec: json.NewDecoder(r)}
+}
+
+// Read returns the next post, or io.EOF when there are no more.
+func (jr *JSONLReader) Read() (*PostsAddOptions, error) {
+	var p PostJSON
+	err := jr.dec.Decode(&p)
+	if err != nil {
+		return nil, err
+	}
+
+	return &PostsAddOptions{
+		URL:         p.Href,
+		Description: p.Description,
+		Extended:    []byte(p.Extended),
+		Tags:        p.Tags,
+		Dt:          p.Time,
+		Shared:      p.Shared,
+		Toread:      p.Toread,
+	}, nil
+}
@@ -0,0 +1,183 @@
+package pinboard
+
+import (
+	"bytes"
+	"io"
+	"strings"
+	"testing"
+)
+
+func TestCSV(t *testing.T) {
+	a := testPost("https://golang.org/", `Go, "the" language`, "go", "lang")
+	a.Extended = []byte("Line one\nline two")
+	a.Shared = true
+	a.Others = 42
+	b := testPost("https://example.com/", "Example")
+	b.Toread = true
+
+	var buf bytes.Buffer
+	w, err := NewCSVWriter(&buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, p := range []*Post{a, b} {
+		err = w.Write(p)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	err = w.Flush()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := "href,description,extended,tags,shared,toread,time,hash,meta,others\r\n" +
+		`https://golang.org/,"Go, ""the"" language","Line one` + "\r\n" +
+		`line two",go lang,yes,no,2010-12-11T19:48:02Z,12fc1b9dc2d516422a91f6aad8e53696,meta-https://golang.org/,42` + "\r\n"
+	if !strings.HasPrefix(buf.String(), expected) {
+		t.Errorf("error: got\n%q\nexpected prefix\n%q", buf.String(), expected)
+	}
+
+	r, err := NewCSVReader(&buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var opts []*PostsAddOptions
+	for {
+		opt, err := r.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+		opts = append(opts, opt)
+	}
+
+	if len(opts) != 2 {
+		t.Fatalf("error: got %v posts, expected 2", len(opts))
+	}
+
+	opt := opts[0]
+	if opt.URL != "https://golang.org/" || opt.Description != `Go, "the" language` || string(opt.Extended) != "Line one\nline two" {
+		t.Errorf("error: got %+v", opt)
+	}
+
+	if len(opt.Tags) != 2 || !opt.Shared || opt.Toread || opt.Dt.Unix() != 1292096882 {
+		t.Errorf("error: got %+v", opt)
+	}
+
+	if !opts[1].Toread || opts[1].Shared {
+		t.Errorf("error: got %+v", opts[1])
+	}
+
+	_, err = NewCSVWriter(&buf, "href", "colour")
+	if err == nil {
+		t.Error("error: expected unknown column error")
+	}
+
+	_, err = NewCSVReader(strings.NewReader("url,title\r\n"))
+	if err == nil {
+		t.Error("error: expected missing column error")
+	}
+
+	r, err = NewCSVReader(strings.NewReader("description,href,shared\r\nGo,https://golang.org/,maybe\r\n"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	_, err = r.Read()
+	if err == nil || !strings.Contains(err.Error(), "record 2") {
+		t.Errorf("error: got %v, expected bad boolean error on record 2", err)
+	}
+}
+
+func TestJSONL(t *testing.T) {
+	a := testPost("https://golang.org/", `Go, "the" language`, "go", "lang")
+	a.Extended = []byte("Line one\nline two")
+	a.Shared = true
+	a.Others = 42
+	b := testPost("https://example.com/", "Example")
+	b.Toread = true
+
+	var buf bytes.Buffer
+	w, err := NewJSONLWriter(&buf, "href", "description", "tags", "shared", "others")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, p := range []*Post{a, b} {
+		err = w.Write(p)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	err = w.Flush()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+	if len(lines) != 2 {
+		t.Fatalf("error: got %v lines, expected 2", len(lines))
+	}
+
+	expected := `{"href":"https://golang.org/","description":"Go, \"the\" language","tags":["go","lang"],"shared":true,"others":42}`
+	if lines[0] != expected {
+		t.Errorf("error: got\n%s\nexpected\n%s", lines[0], expected)
+	}
+
+	if !strings.Contains(lines[1], `"tags":[]`) {
+		t.Errorf("error: expected empty tags array in %s", lines[1])
+	}
+
+	r := NewJSONLReader(&buf)
+	opt, err := r.Read()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if opt.URL != "https://golang.org/" || len(opt.Tags) != 2 || !opt.Shared {
+		t.Errorf("error: got %+v", opt)
+	}
+
+	_, err = r.Read()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	_, err = r.Read()
+	if err != io.EOF {
+		t.Errorf("error: got %v, expected EOF", err)
+	}
+}
+
+func TestTabularUntagged(t *testing.T) {
+	p := apiPost("https://golang.org/", "Go", "")
+
+	var buf bytes.Buffer
+	jw, err := NewJSONLWriter(&buf, "href", "tags")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = jw.Write(p)
+	if err == nil {
+		err = jw.Flush()
+	}
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if expected := `{"href":"https://golang.org/","tags":[]}` + "\n"; buf.String() != expected {
+		t.Errorf("error: got %q, expected %q", buf.String(), expected)
+	}
+
+	if tags := column(p, "tags"); tags != "" {
+		t.Errorf("error: got tags column %q, expected empty", tags)
+	}
+}
@@ -2,7 +2,6 @@ package pinboard
 
 import (
 	"encoding/json"
-	"errors"
 )
 
 // Tags maps a tag name to the number of bookmarks that use that tag.
@@ -51,7 +50,7 @@ func TagsDelete(tag string) error {
 	}
 
 	if tr.Result != "done" {
-		return errors.New(tr.Result)
+		return &ResultError{Result: tr.Result}
 	}
 
 	return nil
@@ -81,7 +80,7 @@ func TagsRename(old, new string) error {
 	}
 
 	if tr.Result != "done" {
-		return errors.New(tr.Result)
+		return &ResultError{Result: tr.Result}
 	}
 
 	return nil
@@ -0,0 +1,415 @@
+package pinboard
+
+import (
+	"bytes"
+	"context"
+	"crypto/md5"
+	"crypto/rand"
+	"crypto/sha1"
+	"encoding/base32"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+// ArchiveEntry records where a bookmark's snapshot is stored.
+type ArchiveEntry struct {
+	// Hash of the bookmark, as in Post.Hash.
+	Hash string `json:"hash"`
+
+	URL string `json:"url"`
+
+	// Name of the WARC file, relative to the archive directory.
+	File string `json:"file"`
+
+	// Number of resources archived, the page included.
+	Resources int `json:"resources"`
+
+	Archived time.Time `json:"archived"`
+}
+
+// Archiver saves snapshots of bookmarked pages, along with their
+// same-origin images, stylesheets and scripts, as WARC files in a
+// local directory. Each bookmark gets a file named after its Hash and
+// an index.json file in the same directory maps bookmarks to files.
+//
+// Resources larger than the Fetcher's MaxBytes are archived cut short
+// and marked with a WARC-Truncated header; raise MaxBytes to archive
+// them whole.
+type Archiver struct {
+	// Fetcher used for downloads. Optional.
+	Fetcher *Fetcher
+
+	// Required: directory the archive is written to.
+	Dir string
+
+	// Only archive bookmarks with at least one of these tags.
+	// Optional.
+	Tags []string
+
+	// Maximum number of assets archived per page. Defaults to 50.
+	MaxAssets int
+
+	// Archive bookmarks again even if they are in the index.
+	Refresh bool
+}
+
+// indexFile is the name of the archive index in the archive
+// directory.
+const indexFile = "index.json"
+
+// Index returns the archive index, keyed by bookmark hash.
+func (a *Archiver) Index() (map[string]ArchiveEntry, error) {
+	index := make(map[string]ArchiveEntry)
+
+	data, err := ioutil.ReadFile(filepath.Join(a.Dir, indexFile))
+	if os.IsNotExist(err) {
+		return index, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	err = json.Unmarshal(data, &index)
+	if err != nil {
+		return nil, err
+	}
+
+	return index, nil
+}
+
+// saveIndex writes the archive index.
+func (a *Archiver) saveIndex(index map[string]ArchiveEntry) error {
+	data, err := json.MarshalIndent(index, "", "\t")
+	if err != nil {
+		return err
+	}
+
+	return writeFileAtomic(filepath.Join(a.Dir, indexFile), data, 0644)
+}
+
+// postHash returns the bookmark's hash. Pinboard's hash is the hex
+// MD5 of the URL, which is used for posts that don't have a valid one
+// since the hash ends up in file names.
+func postHash(p *Post) string {
+	if _, err := hex.DecodeString(string(p.Hash)); err == nil && len(p.Hash) > 0 {
+		return string(p.Hash)
+	}
+
+	sum := md5.Sum([]byte(p.Href.String()))
+	return hex.EncodeToString(sum[:])
+}
+
+// Archive snapshots each post, skipping those filtered out by Tags or
+// already archived, and returns the entries written. A page that
+// fails to download doesn't stop the others; the first such error is
+// returned along with the entries.
+func (a *Archiver) Archive(posts []*Post) ([]ArchiveEntry, error) {
+	err := os.MkdirAll(a.Dir, 0755)
+	if err != nil {
+		return nil, err
+	}
+
+	index, err := a.Index()
+	if err != nil {
+		return nil, err
+	}
+
+	var entries []ArchiveEntry
+	var firstErr error
+	for _, p := range posts {
+		if len(a.Tags) > 0 && !hasAnyTag(p, a.Tags) {
+			continue
+		}
+
+		if _, ok := index[postHash(p)]; ok && !a.Refresh {
+			continue
+		}
+
+		entry, err := a.ArchivePost(p)
+		if err != nil {
+			if firstErr == nil {
+				firstErr = fmt.Errorf("error: %s: %s", p.Href, err)
+			}
+			continue
+		}
+
+		index[entry.Hash] = *entry
+		entries = append(entries, *entry)
+
+		err = a.saveIndex(index)
+		if err != nil {
+			return entries, err
+		}
+	}
+
+	return entries, firstErr
+}
+
+// hasAnyTag reports whether p has at least one of tags.
+func hasAnyTag(p *Post, tags []string) bool {
+	for _, t := range tags {
+		if hasTagFold(p.Tags, t) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// ArchivePost writes a WARC file for a single post without updating
+// the index.
+func (a *Archiver) ArchivePost(p *Post) (*ArchiveEntry, error) {
+	ctx := context.Background()
+
+	var f Fetcher
+	if a.Fetcher != nil {
+		f = *a.Fetcher
+	}
+
+	pg, err := f.get(ctx, p.Href.String())
+	if err != nil {
+		return nil, err
+	}
+
+	if pg.status != http.StatusOK {
+		return nil, fmt.Errorf("error: http %d", pg.status)
+	}
+
+	entry := &ArchiveEntry{
+		Hash:     postHash(p),
+		URL:      p.Href.String(),
+		File:     postHash(p) + ".warc",
+		Archived: time.Now().UTC(),
+	}
+
+	var buf bytes.Buffer
+	w := &warcWriter{w: &buf}
+
+	err = w.warcinfo(entry.Archived)
+	if err != nil {
+		return nil, err
+	}
+
+	err = w.exchange(pg, entry.Archived)
+	if err != nil {
+		return nil, err
+	}
+	entry.Resources++
+
+	max := a.MaxAssets
+	if max == 0 {
+		max = 50
+	}
+
+	assets := findAssets(pg.url, pg.body, pg.contentType)
+	if len(assets) > max {
+		assets = assets[:max]
+	}
+
+	for _, asset := range assets {
+		apg, err := f.get(ctx, asset)
+		if err != nil || apg.status != http.StatusOK {
+			continue
+		}
+
+		err = w.exchange(apg, time.Now().UTC())
+		if err != nil {
+			return nil, err
+		}
+		entry.Resources++
+	}
+
+	err = writeFileAtomic(filepath.Join(a.Dir, entry.File), buf.Bytes(), 0644)
+	if err != nil {
+		return nil, err
+	}
+
+	return entry, nil
+}
+
+// findAssets returns the absolute URLs of the images, stylesheets,
+// scripts and media embedded in an HTML page that have the same
+// origin as the page.
+func findAssets(base *url.URL, body []byte, contentType string) []string {
+	var assets []string
+	seen := make(map[string]bool)
+
+	add := func(ref string) {
+		ref = strings.TrimSpace(ref)
+		if ref == "" || strings.HasPrefix(ref, "data:") {
+			return
+		}
+
+		u, err := base.Parse(ref)
+		if err != nil || u.Scheme != base.Scheme || u.Host != base.Host {
+			return
+		}
+
+		u.Fragment = ""
+		if s := u.String(); !seen[s] && s != base.String() {
+			seen[s] = true
+			assets = append(assets, s)
+		}
+	}
+
+	z := newHTMLTokenizer(decodeHTML(body, contentType))
+	for {
+		t, ok := z.next()
+		if !ok {
+			break
+		}
+
+		if t.typ != startTagToken && t.typ != selfClosingTagToken {
+			continue
+		}
+
+		switch t.data {
+		case "img", "script", "source", "audio", "video", "embed", "input":
+			add(t.attr("src"))
+			for _, candidate := range strings.Split(t.attr("srcset"), ",") {
+				if f := strings.Fields(candidate); len(f) > 0 {
+					add(f[0])
+				}
+			}
+			add(t.attr("poster"))
+		case "link":
+			rel := t.attr("rel")
+			if hasWordFold(rel, "stylesheet") || hasWordFold(rel, "icon") {
+				add(t.attr("href"))
+			}
+		}
+	}
+
+	return assets
+}
+
+// warcWriter writes WARC/1.1 records.
+type warcWriter struct {
+	w      io.Writer
+	infoID string
+}
+
+// newRecordID returns a new random WARC record ID.
+func newRecordID() string {
+	var b [16]byte
+	rand.Read(b[:])
+	b[6] = b[6]&0x0f | 0x40
+	b[8] = b[8]&0x3f | 0x80
+
+	return fmt.Sprintf("<urn:uuid:%x-%x-%x-%x-%x>", b[0:4], b[4:6], b[6:8], b[8:10], b[10:])
+}
+
+// warcDigest returns the WARC style SHA-1 digest of b.
+func warcDigest(b []byte) string {
+	sum := sha1.Sum(b)
+	return "sha1:" + base32.StdEncoding.EncodeToString(sum[:])
+}
+
+// record writes a single WARC record. Headers are written in the order
+// given.
+func (ww *warcWriter) record(headers [][2]string, block []byte) error {
+	var b bytes.Buffer
+	b.WriteString("WARC/1.1\r\n")
+	for _, h := range headers {
+		fmt.Fprintf(&b, "%s: %s\r\n", h[0], h[1])
+	}
+	fmt.Fprintf(&b, "WARC-Block-Digest: %s\r\n", warcDigest(block))
+	fmt.Fprintf(&b, "Content-Length: %d\r\n\r\n", len(block))
+	b.Write(block)
+	b.WriteString("\r\n\r\n")
+
+	_, err := ww.w.Write(b.Bytes())
+	return err
+}
+
+// warcinfo writes the warcinfo record that starts a WARC file.
+func (ww *warcWriter) warcinfo(date time.Time) error {
+	ww.infoID = newRecordID()
+
+	return ww.record([][2]string{
+		{"WARC-Type", "warcinfo"},
+		{"WARC-Record-ID", ww.infoID},
+		{"WARC-Date", date.Format(time.RFC3339)},
+		{"Content-Type", "application/warc-fields"},
+	}, []byte("software: github.com/imwally/pinboard\r\nformat: WARC File Format 1.1\r\n"))
+}
+
+// exchange writes the request and response records for a downloaded
+// page.
+func (ww *warcWriter) exchange(pg *page, date time.Time) error {
+	target := pg.url.String()
+
+	var res bytes.Buffer
+	fmt.Fprintf(&res, "%s %s\r\n", pg.proto, pg.statusText)
+	header := pg.header.Clone()
+	header.Del("Transfer-Encoding")
+
+	// A truncated body keeps the length the server sent, which
+	// along with WARC-Truncated tells readers that it is incomplete.
+	if !pg.truncated {
+		header.Set("Content-Length", fmt.Sprint(len(pg.body)))
+	}
+	writeSortedHeader(&res, header)
+	res.WriteString("\r\n")
+	res.Write(pg.body)
+
+	responseID := newRecordID()
+	headers := [][2]string{
+		{"WARC-Type", "response"},
+		{"WARC-Record-ID", responseID},
+		{"WARC-Warcinfo-ID", ww.infoID},
+		{"WARC-Date", date.Format(time.RFC3339)},
+		{"WARC-Target-URI", target},
+		{"WARC-Payload-Digest", warcDigest(pg.body)},
+	}
+	if pg.truncated {
+		headers = append(headers, [2]string{"WARC-Truncated", "length"})
+	}
+	headers = append(headers, [2]string{"Content-Type", "application/http;msgtype=response"})
+
+	err := ww.record(headers, res.Bytes())
+	if err != nil {
+		return err
+	}
+
+	var req bytes.Buffer
+	fmt.Fprintf(&req, "%s %s %s\r\n", pg.req.Method, pg.url.RequestURI(), pg.proto)
+	fmt.Fprintf(&req, "Host: %s\r\n", pg.url.Host)
+	writeSortedHeader(&req, pg.req.Header)
+	req.WriteString("\r\n")
+
+	return ww.record([][2]string{
+		{"WARC-Type", "request"},
+		{"WARC-Record-ID", newRecordID()},
+		{"WARC-Warcinfo-ID", ww.infoID},
+		{"WARC-Concurrent-To", responseID},
+		{"WARC-Date", date.Format(time.RFC3339)},
+		{"WARC-Target-URI", target},
+		{"Content-Type", "application/http;msgtype=request"},
+	}, req.Bytes())
+}
+
+// writeSortedHeader writes HTTP headers sorted by name.
+func writeSortedHeader(w io.Writer, h http.Header) {
+	var keys []string
+	for k := range h {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	for _, k := range keys {
+		for _, v := range h[k] {
+			fmt.Fprintf(w, "%s: %s\r\n", k, v)
+		}
+	}
+}
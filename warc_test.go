@@ -0,0 +1,139 @@
+package pinboard
+
+import (
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestArchiver(t *testing.T) {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/page", func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, `<html><head><link rel="stylesheet" href="/style.css">`+
+			`<script src="https://cdn.example.com/lib.js"></script></head>`+
+			`<body><img src="logo.png" srcset="logo.png 1x, logo@2x.png 2x"><img src="data:image/png;base64,AAAA"></body></html>`)
+	})
+	mux.HandleFunc("/style.css", func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, "body { color: black }")
+	})
+	mux.HandleFunc("/logo.png", func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("\x89PNG"))
+	})
+	mux.HandleFunc("/large", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Length", "4096")
+		w.Write(bytes.Repeat([]byte("x"), 4096))
+	})
+
+	srv := httptest.NewServer(mux)
+	defer srv.Close()
+
+	dir, err := ioutil.TempDir("", "archive")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	page := testPost(srv.URL+"/page", "Page", "keep")
+	skipped := testPost(srv.URL+"/style.css", "Style", "other")
+
+	a := &Archiver{Dir: dir, Tags: []string{"keep"}}
+	entries, err := a.Archive([]*Post{page, skipped})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// The page, the stylesheet and the logo. The 2x logo is
+	// missing and the script is on another origin.
+	if len(entries) != 1 || entries[0].Resources != 3 {
+		t.Fatalf("error: unexpected entries %+v", entries)
+	}
+
+	if entries[0].Hash != string(page.Hash) || entries[0].File != string(page.Hash)+".warc" {
+		t.Errorf("error: got %v in %v, expected the file to be named after the bookmark hash", entries[0].Hash, entries[0].File)
+	}
+
+	data, err := ioutil.ReadFile(filepath.Join(dir, entries[0].File))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !bytes.HasPrefix(data, []byte("WARC/1.1\r\nWARC-Type: warcinfo\r\n")) {
+		t.Error("error: expected file to start with a warcinfo record")
+	}
+
+	if n := bytes.Count(data, []byte("WARC-Type: response\r\n")); n != 3 {
+		t.Errorf("error: got %v, expected 3 response records", n)
+	}
+
+	if n := bytes.Count(data, []byte("WARC-Type: request\r\n")); n != 3 {
+		t.Errorf("error: got %v, expected 3 request records", n)
+	}
+
+	if !bytes.Contains(data, []byte("WARC-Target-URI: "+srv.URL+"/style.css\r\n")) {
+		t.Error("error: expected stylesheet to be archived")
+	}
+
+	index, err := a.Index()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if index[string(page.Hash)].File != entries[0].File {
+		t.Errorf("error: unexpected index %v", index)
+	}
+
+	if bytes.Contains(data, []byte("WARC-Truncated")) {
+		t.Error("error: expected complete records not to be marked truncated")
+	}
+
+	// Already archived.
+	entries, err = a.Archive([]*Post{page})
+	if err != nil || len(entries) != 0 {
+		t.Errorf("error: expected archived post to be skipped, got %v %v", entries, err)
+	}
+
+	a = &Archiver{Fetcher: &Fetcher{MaxBytes: 1024}, Dir: dir}
+	entry, err := a.ArchivePost(testPost(srv.URL+"/large", "Large"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	data, err = ioutil.ReadFile(filepath.Join(dir, entry.File))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !bytes.Contains(data, []byte("WARC-Truncated: length\r\n")) || !bytes.Contains(data, []byte("Content-Length: 4096\r\n")) || !bytes.Contains(data, []byte("\r\n\r\n"+strings.Repeat("x", 1024)+"\r\n\r\n")) {
+		t.Error("error: expected truncated response to keep its length and be marked truncated")
+	}
+}
+
+func TestPostHash(t *testing.T) {
+	missing := testPost("https://example.com/article", "")
+	missing.Hash = nil
+
+	invalid := testPost("https://example.com/article", "")
+	invalid.Hash = []byte("../article")
+
+	tests := []struct {
+		post     *Post
+		expected string
+	}{
+		{testPost("https://example.com/article", ""), "ae424022b605a832ae799726747f893e"},
+		{missing, "141fbc787408697a5d22735982be532a"},
+		{invalid, "141fbc787408697a5d22735982be532a"},
+	}
+
+	for _, test := range tests {
+		got := postHash(test.post)
+		if got != test.expected {
+			t.Errorf("error: got %v, expected %v", got, test.expected)
+		}
+	}
+}
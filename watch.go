@@ -0,0 +1,284 @@
+package pinboard
+
+import (
+	"context"
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"sort"
+	"time"
+)
+
+// EventType is the kind of change reported by a Watcher.
+type EventType string
+
+const (
+	EventAdded    EventType = "added"
+	EventModified EventType = "modified"
+	EventDeleted  EventType = "deleted"
+)
+
+// Event is a change to a single bookmark.
+type Event struct {
+	Type EventType
+
+	// URL of the bookmark.
+	URL string
+
+	// The bookmark as it is now. Nil for EventDeleted events.
+	Post *Post
+
+	// Tags of the bookmark, as last seen for EventDeleted events.
+	Tags []string
+}
+
+// WatchState is the last state seen by a Watcher. It is saved after
+// every change so that a restarted Watcher doesn't replay old events.
+type WatchState struct {
+	// Last time reported by PostsUpdate.
+	UpdateTime time.Time `json:"update_time"`
+
+	// Change detection signature (Meta) of every bookmark, keyed
+	// by URL.
+	Meta map[string]string `json:"meta"`
+
+	// Tags of every bookmark, keyed by URL, so that deleted
+	// bookmarks can be matched by tag.
+	Tags map[string][]string `json:"tags,omitempty"`
+}
+
+const (
+	// DefaultWatchInterval is how often a Watcher calls
+	// PostsUpdate by default.
+	DefaultWatchInterval = time.Minute
+
+	// postsAllInterval is the minimum time Pinboard allows between
+	// calls to /posts/all.
+	postsAllInterval = 5 * time.Minute
+)
+
+// Watcher polls PostsUpdate and reports bookmarks that were added,
+// modified or deleted since the last poll.
+//
+// When the update time moves forward the Watcher fetches PostsAll and
+// compares each bookmark's Meta signature with the saved state. A
+// date filtered fetch like PostsRecent would be cheaper but can't see
+// deletions or edits to older bookmarks. PostsAll is called at most
+// once every five minutes, as Pinboard asks.
+type Watcher struct {
+	// How often to call PostsUpdate. Defaults to
+	// DefaultWatchInterval.
+	Interval time.Duration
+
+	// File the state is saved to. If empty, state is kept in
+	// memory only.
+	StatePath string
+
+	api     watchAPI
+	state   WatchState
+	lastAll time.Time
+}
+
+// watchAPI holds the API calls made by a Watcher, so that tests can
+// replace them.
+type watchAPI struct {
+	postsUpdate func() (time.Time, error)
+	postsAll    func(opt *PostsAllOptions) ([]*Post, error)
+}
+
+var defaultWatchAPI = watchAPI{
+	postsUpdate: PostsUpdate,
+	postsAll:    PostsAll,
+}
+
+// NewWatcher returns a Watcher that saves its state to statePath,
+// loading any state saved by a previous run.
+func NewWatcher(statePath string) (*Watcher, error) {
+	w := &Watcher{StatePath: statePath, api: defaultWatchAPI}
+
+	if statePath == "" {
+		return w, nil
+	}
+
+	data, err := ioutil.ReadFile(statePath)
+	if err != nil && !os.IsNotExist(err) {
+		return nil, err
+	}
+
+	if len(data) > 0 {
+		err = json.Unmarshal(data, &w.state)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return w, nil
+}
+
+// State returns the last state seen by the watcher.
+func (w *Watcher) State() WatchState {
+	return w.state
+}
+
+// Poll checks PostsUpdate once, saves the new state and returns the
+// changes since the previous poll. The first poll without saved state
+// records a baseline and returns no events.
+func (w *Watcher) Poll() ([]Event, error) {
+	events, commit, err := w.poll()
+	if err != nil {
+		return nil, err
+	}
+
+	return events, commit()
+}
+
+// poll is like Poll but leaves the new state to be saved by commit,
+// once the events have been handled.
+func (w *Watcher) poll() ([]Event, func() error, error) {
+	api := w.api
+	if api.postsAll == nil {
+		api = defaultWatchAPI
+	}
+
+	nothing := func() error { return nil }
+
+	update, err := api.postsUpdate()
+	if err != nil {
+		return nil, nil, err
+	}
+
+	if w.state.Meta != nil && !update.After(w.state.UpdateTime) {
+		return nil, nothing, nil
+	}
+
+	// Too soon to call /posts/all again. The update time isn't
+	// saved, so the change is picked up by a later poll.
+	if time.Since(w.lastAll) < postsAllInterval {
+		return nil, nothing, nil
+	}
+
+	posts, err := api.postsAll(nil)
+	if err != nil {
+		return nil, nil, err
+	}
+	w.lastAll = time.Now()
+
+	events, state := diffSnapshot(w.state, posts)
+	if w.state.Meta == nil {
+		events = nil
+	}
+	state.UpdateTime = update
+
+	return events, func() error {
+		w.state = state
+		return w.save()
+	}, nil
+}
+
+// Run polls until ctx is done, calling fn with every event. The state
+// is saved only after fn has handled every event of a poll: if fn
+// returns an error, Run stops and returns it, and a later poll reports
+// the events again, including those fn had already handled. Temporary
+// errors such as rate limiting or network failures are retried on the
+// next poll; any other error stops Run.
+func (w *Watcher) Run(ctx context.Context, fn func(Event) error) error {
+	interval := w.Interval
+	if interval == 0 {
+		interval = DefaultWatchInterval
+	}
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		events, commit, err := w.poll()
+		if err != nil && !transient(err) {
+			return err
+		}
+
+		if err == nil {
+			for _, e := range events {
+				err = fn(e)
+				if err != nil {
+					return err
+				}
+			}
+
+			err = commit()
+			if err != nil {
+				return err
+			}
+		}
+
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-ticker.C:
+		}
+	}
+}
+
+// Watch is like Run but sends events on a channel.
+func (w *Watcher) Watch(ctx context.Context, events chan<- Event) error {
+	return w.Run(ctx, func(e Event) error {
+		select {
+		case events <- e:
+			return nil
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	})
+}
+
+// save writes the state to StatePath.
+func (w *Watcher) save() error {
+	if w.StatePath == "" {
+		return nil
+	}
+
+	data, err := json.Marshal(w.state)
+	if err != nil {
+		return err
+	}
+
+	return writeFileAtomic(w.StatePath, data, 0600)
+}
+
+// diffSnapshot compares posts against the previous state and returns
+// the events along with the new state.
+func diffSnapshot(old WatchState, posts []*Post) ([]Event, WatchState) {
+	state := WatchState{
+		Meta: make(map[string]string),
+		Tags: make(map[string][]string),
+	}
+
+	var events []Event
+	for _, p := range posts {
+		u := p.Href.String()
+		tags := cleanTags(p.Tags)
+		state.Meta[u] = string(p.Meta)
+		state.Tags[u] = tags
+
+		m, ok := old.Meta[u]
+		switch {
+		case !ok:
+			events = append(events, Event{Type: EventAdded, URL: u, Post: p, Tags: tags})
+		case m != string(p.Meta):
+			events = append(events, Event{Type: EventModified, URL: u, Post: p, Tags: tags})
+		}
+	}
+
+	var deleted []string
+	for u := range old.Meta {
+		if _, ok := state.Meta[u]; !ok {
+			deleted = append(deleted, u)
+		}
+	}
+	sort.Strings(deleted)
+
+	for _, u := range deleted {
+		events = append(events, Event{Type: EventDeleted, URL: u, Tags: old.Tags[u]})
+	}
+
+	return events, state
+}
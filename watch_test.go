@@ -0,0 +1,203 @@
+package pinboard
+
+import (
+	"context"
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestDiffSnapshot(t *testing.T) {
+	modified := testPost("https://golang.org/", "Go")
+	modified.Meta = []byte("changed")
+
+	old := WatchState{
+		Meta: map[string]string{
+			"https://golang.org/":  "meta-https://golang.org/",
+			"https://pinboard.in/": "meta-https://pinboard.in/",
+			"https://deleted.com/": "meta-https://deleted.com/",
+		},
+		Tags: map[string][]string{
+			"https://deleted.com/": {"old"},
+		},
+	}
+
+	posts := []*Post{
+		modified,
+		testPost("https://pinboard.in/", "Pinboard"),
+		testPost("https://added.com/", "Added", "new"),
+	}
+
+	events, state := diffSnapshot(old, posts)
+
+	expected := []Event{
+		{Type: EventModified, URL: "https://golang.org/"},
+		{Type: EventAdded, URL: "https://added.com/"},
+		{Type: EventDeleted, URL: "https://deleted.com/"},
+	}
+
+	if len(events) != len(expected) {
+		t.Fatalf("error: got %v, expected %v events", len(events), len(expected))
+	}
+
+	for i, e := range expected {
+		if events[i].Type != e.Type || events[i].URL != e.URL {
+			t.Errorf("error: got %v %v, expected %v %v", events[i].Type, events[i].URL, e.Type, e.URL)
+		}
+	}
+
+	if events[2].Post != nil {
+		t.Error("error: expected nil post for deleted event")
+	}
+
+	if len(events[2].Tags) != 1 || events[2].Tags[0] != "old" {
+		t.Errorf("error: got %v, expected last seen tags for deleted event", events[2].Tags)
+	}
+
+	if len(state.Meta) != 3 || state.Meta["https://golang.org/"] != "changed" {
+		t.Errorf("error: unexpected meta %v", state.Meta)
+	}
+
+	if tags := state.Tags["https://added.com/"]; len(tags) != 1 || tags[0] != "new" {
+		t.Errorf("error: got %v, expected tags of added bookmark in state", tags)
+	}
+}
+
+// testWatchAPI is a watchAPI serving the posts and update time it
+// holds, counting calls to PostsAll.
+type testWatchAPI struct {
+	update time.Time
+	posts  []*Post
+	all    int
+}
+
+func (api *testWatchAPI) watchAPI() watchAPI {
+	return watchAPI{
+		postsUpdate: func() (time.Time, error) {
+			return api.update, nil
+		},
+		postsAll: func(opt *PostsAllOptions) ([]*Post, error) {
+			api.all++
+			return api.posts, nil
+		},
+	}
+}
+
+func TestWatcherPoll(t *testing.T) {
+	dir, err := ioutil.TempDir("", "watch")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	path := filepath.Join(dir, "state.json")
+	w, err := NewWatcher(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	api := &testWatchAPI{
+		update: time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC),
+		posts:  []*Post{testPost("https://golang.org/", "Go", "go")},
+	}
+	w.api = api.watchAPI()
+
+	// The first poll records a baseline.
+	events, err := w.Poll()
+	if err != nil || len(events) != 0 || api.all != 1 {
+		t.Fatalf("error: got %v %v after %v calls, expected a baseline", events, err, api.all)
+	}
+
+	saved, err := NewWatcher(path)
+	if err != nil || len(saved.State().Meta) != 1 || !saved.State().UpdateTime.Equal(api.update) {
+		t.Fatalf("error: got %+v %v, expected the baseline to be saved", saved.State(), err)
+	}
+
+	// Nothing changed.
+	events, err = w.Poll()
+	if err != nil || len(events) != 0 || api.all != 1 {
+		t.Errorf("error: got %v %v after %v calls, expected no change", events, err, api.all)
+	}
+
+	// Changed, but too soon to call PostsAll again.
+	last := api.update
+	api.update = api.update.Add(time.Hour)
+	api.posts = append(api.posts, testPost("https://pinboard.in/", "Pinboard"))
+
+	events, err = w.Poll()
+	if err != nil || len(events) != 0 || api.all != 1 || !w.State().UpdateTime.Equal(last) {
+		t.Errorf("error: got %v %v after %v calls, expected the change to wait", events, err, api.all)
+	}
+
+	w.lastAll = time.Now().Add(-postsAllInterval)
+	events, err = w.Poll()
+	if err != nil || len(events) != 1 || events[0].Type != EventAdded || api.all != 2 {
+		t.Fatalf("error: got %v %v after %v calls, expected pinboard.in to be added", events, err, api.all)
+	}
+
+	saved, err = NewWatcher(path)
+	if err != nil || len(saved.State().Meta) != 2 || !saved.State().UpdateTime.Equal(api.update) {
+		t.Errorf("error: got %+v %v, expected the new state to be saved", saved.State(), err)
+	}
+}
+
+func TestWatcherRun(t *testing.T) {
+	dir, err := ioutil.TempDir("", "watch")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	path := filepath.Join(dir, "state.json")
+	api := &testWatchAPI{
+		update: time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC),
+		posts:  []*Post{testPost("https://golang.org/", "Go")},
+	}
+
+	w, _ := NewWatcher(path)
+	w.api = api.watchAPI()
+	_, err = w.Poll()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	api.update = api.update.Add(time.Hour)
+	api.posts = []*Post{testPost("https://pinboard.in/", "Pinboard")}
+
+	// A failed callback stops Run without saving the poll.
+	w, _ = NewWatcher(path)
+	w.api = api.watchAPI()
+	w.Interval = time.Millisecond
+
+	failed := errors.New("error: delivery failed")
+	err = w.Run(context.Background(), func(e Event) error {
+		return failed
+	})
+	if err != failed {
+		t.Fatalf("error: got %v, expected the callback error", err)
+	}
+
+	// The events are reported again.
+	w, _ = NewWatcher(path)
+	w.api = api.watchAPI()
+	w.Interval = time.Millisecond
+
+	ctx, cancel := context.WithCancel(context.Background())
+	var got []Event
+	err = w.Run(ctx, func(e Event) error {
+		got = append(got, e)
+		cancel()
+		return nil
+	})
+	if err != context.Canceled || len(got) != 2 {
+		t.Fatalf("error: got %v and events %v, expected the events again", err, got)
+	}
+
+	saved, _ := NewWatcher(path)
+	if !saved.State().UpdateTime.Equal(api.update) {
+		t.Errorf("error: got update time %v, expected the handled poll to be saved", saved.State().UpdateTime)
+	}
+}
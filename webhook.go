@@ -0,0 +1,242 @@
+package pinboard
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"strings"
+	"sync"
+	"time"
+)
+
+// SignatureHeader is the HTTP header holding the HMAC-SHA256
+// signature of a webhook payload, formatted as "sha256=<hex>".
+const SignatureHeader = "X-Pinboard-Signature"
+
+// WebhookTarget is a URL that receives webhook payloads.
+type WebhookTarget struct {
+	// Required: URL to POST payloads to.
+	URL string
+
+	// Key used to sign payloads.
+	Secret []byte
+
+	// Only deliver events for bookmarks with at least one of these
+	// tags. Deleted bookmarks are matched by the tags they had when
+	// last seen.
+	Tags []string
+}
+
+// wants reports whether the target should receive e.
+func (t *WebhookTarget) wants(e Event) bool {
+	if len(t.Tags) == 0 {
+		return true
+	}
+
+	tags := e.Tags
+	if e.Post != nil {
+		tags = e.Post.Tags
+	}
+
+	for _, want := range t.Tags {
+		for _, tag := range tags {
+			if tag == want {
+				return true
+			}
+		}
+	}
+
+	return false
+}
+
+// WebhookPayload is the JSON body POSTed to webhook targets.
+type WebhookPayload struct {
+	Event EventType `json:"event"`
+	URL   string    `json:"url"`
+	Time  time.Time `json:"time"`
+
+	// Nil for deleted events.
+	Bookmark *PostJSON `json:"bookmark,omitempty"`
+
+	// Tags the bookmark had when it was last seen. Only set for
+	// deleted events.
+	Tags []string `json:"tags,omitempty"`
+}
+
+// Webhooks delivers bookmark change events to webhook targets.
+type Webhooks struct {
+	Targets []WebhookTarget
+
+	// Client used for deliveries. Defaults to http.DefaultClient.
+	Client *http.Client
+
+	// Number of times to retry a failed delivery. Defaults to 3; a
+	// negative number turns retries off.
+	Retries int
+
+	// Delay before the first retry, doubled after each attempt.
+	// Defaults to one second.
+	Backoff time.Duration
+
+	// File that failed deliveries are appended to as JSON lines.
+	// If empty, failed deliveries are returned as errors.
+	DeadLetter string
+
+	mu sync.Mutex
+}
+
+// deadLetter is a line in the dead-letter file.
+type deadLetter struct {
+	Target  string          `json:"target"`
+	Error   string          `json:"error"`
+	Time    time.Time       `json:"time"`
+	Payload json.RawMessage `json:"payload"`
+}
+
+// Deliver sends e to every target that wants it. A failed delivery
+// doesn't stop the others; the errors of those that aren't written to
+// DeadLetter are returned together.
+func (wh *Webhooks) Deliver(e Event) error {
+	payload := WebhookPayload{Event: e.Type, URL: e.URL, Time: time.Now().UTC()}
+	if e.Post != nil {
+		payload.Bookmark = e.Post.toJSON()
+	} else {
+		payload.Tags = e.Tags
+	}
+
+	body, err := json.Marshal(payload)
+	if err != nil {
+		return err
+	}
+
+	var errs []string
+	for i := range wh.Targets {
+		t := &wh.Targets[i]
+		if !t.wants(e) {
+			continue
+		}
+
+		err := wh.send(t, body)
+		if err != nil && wh.DeadLetter != "" {
+			err = wh.bury(t, body, err)
+		}
+		if err != nil {
+			errs = append(errs, err.Error())
+		}
+	}
+
+	if len(errs) > 0 {
+		return errors.New(strings.Join(errs, "; "))
+	}
+
+	return nil
+}
+
+// Run delivers every event reported by w until ctx is done. A failed
+// delivery that isn't written to DeadLetter stops Run and its error is
+// returned. The watcher state of that poll isn't saved, so its events
+// are delivered again when Run is next called.
+func (wh *Webhooks) Run(ctx context.Context, w *Watcher) error {
+	return w.Run(ctx, wh.Deliver)
+}
+
+// send POSTs body to t, retrying with backoff.
+func (wh *Webhooks) send(t *WebhookTarget, body []byte) error {
+	client := wh.Client
+	if client == nil {
+		client = http.DefaultClient
+	}
+
+	retries := wh.Retries
+	switch {
+	case retries == 0:
+		retries = 3
+	case retries < 0:
+		retries = 0
+	}
+
+	backoff := wh.Backoff
+	if backoff == 0 {
+		backoff = time.Second
+	}
+
+	mac := hmac.New(sha256.New, t.Secret)
+	mac.Write(body)
+	signature := "sha256=" + hex.EncodeToString(mac.Sum(nil))
+
+	var err error
+	for attempt := 0; attempt <= retries; attempt++ {
+		if attempt > 0 {
+			time.Sleep(backoff)
+			backoff *= 2
+		}
+
+		var req *http.Request
+		req, err = http.NewRequest(http.MethodPost, t.URL, bytes.NewReader(body))
+		if err != nil {
+			return err
+		}
+		req.Header.Set("Content-Type", "application/json")
+		req.Header.Set(SignatureHeader, signature)
+
+		var res *http.Response
+		res, err = client.Do(req)
+		if err != nil {
+			continue
+		}
+		res.Body.Close()
+
+		if res.StatusCode >= 200 && res.StatusCode < 300 {
+			return nil
+		}
+		err = fmt.Errorf("error: %s: http %d", t.URL, res.StatusCode)
+	}
+
+	return err
+}
+
+// bury appends a failed delivery to the dead-letter file.
+func (wh *Webhooks) bury(t *WebhookTarget, body []byte, cause error) error {
+	line, err := json.Marshal(deadLetter{
+		Target:  t.URL,
+		Error:   cause.Error(),
+		Time:    time.Now().UTC(),
+		Payload: body,
+	})
+	if err != nil {
+		return err
+	}
+
+	wh.mu.Lock()
+	defer wh.mu.Unlock()
+
+	f, err := os.OpenFile(wh.DeadLetter, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0600)
+	if err != nil {
+		return err
+	}
+
+	_, err = f.Write(append(line, '\n'))
+	if cerr := f.Close(); err == nil {
+		err = cerr
+	}
+
+	return err
+}
+
+// VerifySignature reports whether signature, the value of the
+// SignatureHeader, is a valid signature of body under secret. It is
+// meant for receivers of webhook payloads.
+func VerifySignature(secret, body []byte, signature string) bool {
+	mac := hmac.New(sha256.New, secret)
+	mac.Write(body)
+	expected := "sha256=" + hex.EncodeToString(mac.Sum(nil))
+
+	return hmac.Equal([]byte(expected), []byte(signature))
+}
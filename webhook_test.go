@@ -0,0 +1,204 @@
+package pinboard
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestWebhooksDeliver(t *testing.T) {
+	secret := []byte("secret")
+
+	var got []WebhookPayload
+	var attempts int
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		attempts++
+
+		body, _ := ioutil.ReadAll(r.Body)
+		if !VerifySignature(secret, body, r.Header.Get(SignatureHeader)) {
+			t.Error("error: invalid signature")
+		}
+
+		// Fail the first attempt to exercise retries.
+		if attempts == 1 {
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+
+		var p WebhookPayload
+		json.Unmarshal(body, &p)
+		got = append(got, p)
+	}))
+	defer srv.Close()
+
+	wh := &Webhooks{
+		Targets: []WebhookTarget{{URL: srv.URL, Secret: secret, Tags: []string{"go"}}},
+		Backoff: time.Millisecond,
+	}
+
+	post := testPost("https://golang.org/", "Go", "go")
+
+	err := wh.Deliver(Event{Type: EventAdded, URL: post.Href.String(), Post: post})
+	if err != nil {
+		t.Error(err)
+	}
+
+	// Filtered out by tag.
+	err = wh.Deliver(Event{Type: EventDeleted, URL: "https://pinboard.in/", Tags: []string{"bookmarks"}})
+	if err != nil {
+		t.Error(err)
+	}
+
+	// Matched by the tags it had before it was deleted.
+	err = wh.Deliver(Event{Type: EventDeleted, URL: "https://go.dev/", Tags: []string{"go"}})
+	if err != nil {
+		t.Error(err)
+	}
+
+	if len(got) != 2 || got[0].Event != EventAdded || got[0].Bookmark.Description != "Go" {
+		t.Fatalf("error: unexpected payloads %v", got)
+	}
+
+	if got[1].Event != EventDeleted || got[1].URL != "https://go.dev/" || got[1].Bookmark != nil || len(got[1].Tags) != 1 {
+		t.Errorf("error: unexpected deleted payload %v", got[1])
+	}
+
+	if attempts != 3 {
+		t.Errorf("error: got %v, expected 3 attempts", attempts)
+	}
+}
+
+func TestWebhooksDeadLetter(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusBadGateway)
+	}))
+	defer srv.Close()
+
+	dir, err := ioutil.TempDir("", "webhook")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	wh := &Webhooks{
+		Targets:    []WebhookTarget{{URL: srv.URL}},
+		Retries:    1,
+		Backoff:    time.Millisecond,
+		DeadLetter: filepath.Join(dir, "dead.jsonl"),
+	}
+
+	err = wh.Deliver(Event{Type: EventDeleted, URL: "https://pinboard.in/"})
+	if err != nil {
+		t.Error(err)
+	}
+
+	data, err := ioutil.ReadFile(wh.DeadLetter)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if bytes.Count(data, []byte("\n")) != 1 || !bytes.Contains(data, []byte("https://pinboard.in/")) {
+		t.Errorf("error: unexpected dead letter file %s", data)
+	}
+}
+
+func TestWebhooksDeliverEveryTarget(t *testing.T) {
+	var failed int
+	bad := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		failed++
+		w.WriteHeader(http.StatusInternalServerError)
+	}))
+	defer bad.Close()
+
+	var delivered int
+	good := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		delivered++
+	}))
+	defer good.Close()
+
+	wh := &Webhooks{
+		Targets: []WebhookTarget{{URL: bad.URL}, {URL: good.URL}},
+		Retries: -1,
+	}
+
+	err := wh.Deliver(Event{Type: EventDeleted, URL: "https://pinboard.in/"})
+	if err == nil || !strings.Contains(err.Error(), bad.URL) {
+		t.Errorf("error: got %v, expected the failed target's error", err)
+	}
+
+	if failed != 1 || delivered != 1 {
+		t.Errorf("error: got %v failed and %v delivered, expected one attempt at each target", failed, delivered)
+	}
+}
+
+func TestWebhooksRun(t *testing.T) {
+	down := true
+	var got []WebhookPayload
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if down {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+
+		var p WebhookPayload
+		json.NewDecoder(r.Body).Decode(&p)
+		got = append(got, p)
+	}))
+	defer srv.Close()
+
+	dir, err := ioutil.TempDir("", "webhook")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	path := filepath.Join(dir, "state.json")
+	api := &testWatchAPI{
+		update: time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC),
+		posts:  []*Post{testPost("https://golang.org/", "Go")},
+	}
+
+	w, _ := NewWatcher(path)
+	w.api = api.watchAPI()
+	_, err = w.Poll()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	api.update = api.update.Add(time.Hour)
+	api.posts = append(api.posts, testPost("https://pinboard.in/", "Pinboard"))
+
+	wh := &Webhooks{Targets: []WebhookTarget{{URL: srv.URL}}, Retries: -1}
+
+	w, _ = NewWatcher(path)
+	w.api = api.watchAPI()
+	err = wh.Run(context.Background(), w)
+	if err == nil {
+		t.Fatal("error: expected the failed delivery to stop Run")
+	}
+
+	// Once the target is back, the event is delivered.
+	down = false
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+
+	w, _ = NewWatcher(path)
+	w.api = api.watchAPI()
+	w.Interval = time.Millisecond
+	err = wh.Run(ctx, w)
+	if err != context.DeadlineExceeded {
+		t.Errorf("error: got %v, expected Run to stop with its context", err)
+	}
+
+	if len(got) != 1 || got[0].URL != "https://pinboard.in/" {
+		t.Errorf("error: got %v, expected pinboard.in to be delivered once", got)
+	}
+}